@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"errors"
+	"github.com/happyxcj/golib/internal/timerpool"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// 批量执行器：累积提交的元素，达到maxSize个或者距本批第一个元素提交超过maxDelay时长时，
+// 把本批元素交给handler处理，例如：批量写入数据库
+type Batcher struct {
+	// 每批最多元素个数
+	maxSize int
+	// 每批最长等待时长
+	maxDelay time.Duration
+	// 处理一批元素的方法
+	handler func(items []interface{})
+	// 执行handler的异步工作者，为nil时在批量执行器的协程中执行
+	worker *FnWorker
+	// 处理handler执行panic的方法，参数为recover()的值和panic时的调用栈
+	panicHandler func(r interface{}, stack []byte)
+	// 待处理元素队列
+	itemCh chan interface{}
+	// 开始停止时关闭，通知阻塞的提交方返回失败
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// 保护提交与停止的并发，停止后不会再有元素入队
+	mu sync.RWMutex
+	// 没有提交方后关闭，通知处理协程处理完剩余元素后退出
+	quitCh chan struct{}
+	// 处理协程退出时关闭
+	doneCh chan struct{}
+}
+
+// NewBatcher 返回每批最多maxSize个元素、最长等待maxDelay时长，队列长度为queueSize的批量执行器
+func NewBatcher(maxSize int, maxDelay time.Duration, queueSize int, handler func(items []interface{})) *Batcher {
+	if handler == nil {
+		panic("handler of batcher is nil")
+	}
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	b := &Batcher{
+		maxSize:      maxSize,
+		maxDelay:     maxDelay,
+		handler:      handler,
+		panicHandler: logPanic,
+		itemCh:       make(chan interface{}, queueSize),
+		stopCh:       make(chan struct{}),
+		quitCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+// WithWorker 设置执行handler的异步工作者w，使处理慢的批次不阻塞后续元素的累积
+// 注意：需要在提交元素前设置
+func (b *Batcher) WithWorker(w *FnWorker) *Batcher {
+	b.worker = w
+	return b
+}
+
+// WithPanicHandler 设置处理handler执行panic的方法，参数为recover()的值和panic时的调用栈，
+// 设置了异步工作者时由异步工作者处理panic
+// 注意：需要在提交元素前设置
+func (b *Batcher) WithPanicHandler(handler func(r interface{}, stack []byte)) *Batcher {
+	b.panicHandler = handler
+	return b
+}
+
+// isStopped 返回批量执行器是否已经开始停止
+func (b *Batcher) isStopped() bool {
+	select {
+	case <-b.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// 提交指定元素item直至成功，批量执行器停止时返回ErrWorkerStopped
+func (b *Batcher) Add(item interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.isStopped() {
+		return ErrWorkerStopped
+	}
+	select {
+	case b.itemCh <- item:
+		return nil
+	case <-b.stopCh:
+		return ErrWorkerStopped
+	}
+}
+
+// 提交指定元素item，失败时(队列满了)直接返回，不等待
+func (b *Batcher) AddRightAway(item interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.isStopped() {
+		return ErrWorkerStopped
+	}
+	select {
+	case b.itemCh <- item:
+		return nil
+	default:
+		return errors.New("slow consumer detected")
+	}
+}
+
+// Stop 停止批量执行器：此后提交的元素返回ErrWorkerStopped，等待已提交的元素都交给handler后返回
+// 注意：设置了异步工作者时，不等待异步工作者执行完handler
+func (b *Batcher) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopCh)
+		// 等待正在进行的提交完成
+		b.mu.Lock()
+		close(b.quitCh)
+		b.mu.Unlock()
+	})
+	<-b.doneCh
+}
+
+func (b *Batcher) loop() {
+	defer close(b.doneCh)
+	batch := make([]interface{}, 0, b.maxSize)
+	var t *time.Timer
+	var timeoutCh <-chan time.Time
+	flush := func() {
+		if t != nil {
+			timerpool.Release(t)
+			t, timeoutCh = nil, nil
+		}
+		if len(batch) > 0 {
+			b.dispatch(batch)
+			batch = make([]interface{}, 0, b.maxSize)
+		}
+	}
+	add := func(item interface{}) {
+		batch = append(batch, item)
+		if len(batch) == 1 {
+			t = timerpool.Acquire(b.maxDelay)
+			timeoutCh = t.C
+		}
+		if len(batch) >= b.maxSize {
+			flush()
+		}
+	}
+	for {
+		select {
+		case item := <-b.itemCh:
+			add(item)
+		case <-timeoutCh:
+			flush()
+		case <-b.quitCh:
+			for len(b.itemCh) > 0 {
+				add(<-b.itemCh)
+			}
+			flush()
+			return
+		}
+	}
+}
+
+// dispatch 把一批元素交给handler处理，异步工作者已经停止时在当前协程中处理
+func (b *Batcher) dispatch(items []interface{}) {
+	fn := func() { b.handler(items) }
+	if b.worker != nil && b.worker.Serve(fn) == nil {
+		return
+	}
+	b.run(fn)
+}
+
+// run 执行方法fn，fn发生panic时恢复并交给panicHandler处理，使处理协程继续服务
+func (b *Batcher) run(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if b.panicHandler != nil {
+				b.panicHandler(r, debug.Stack())
+			}
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]interface{}
+	b := NewBatcher(3, time.Millisecond*50, 10, func(items []interface{}) {
+		mu.Lock()
+		batches = append(batches, items)
+		mu.Unlock()
+	})
+	getBatches := func() [][]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return batches
+	}
+	// 达到个数上限时立即处理
+	for i := 0; i < 4; i++ {
+		if err := b.Add(i); err != nil {
+			t.Fatalf("TestBatcher Add err: %v", err)
+		}
+	}
+	time.Sleep(time.Millisecond * 10)
+	if bs := getBatches(); len(bs) != 1 || len(bs[0]) != 3 {
+		t.Fatalf("TestBatcher size flush err, get: %v", bs)
+	}
+	// 超过等待时长时处理
+	time.Sleep(time.Millisecond * 60)
+	if bs := getBatches(); len(bs) != 2 || len(bs[1]) != 1 || bs[1][0] != 3 {
+		t.Fatalf("TestBatcher time flush err, get: %v", bs)
+	}
+	// 停止时处理剩余元素
+	b.AddRightAway(4)
+	b.AddRightAway(5)
+	b.Stop()
+	if bs := getBatches(); len(bs) != 3 || len(bs[2]) != 2 {
+		t.Fatalf("TestBatcher stop flush err, get: %v", bs)
+	}
+	if err := b.Add(6); err != ErrWorkerStopped {
+		t.Fatalf("TestBatcher Add after stop err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+}
+
+func TestBatcherWithWorker(t *testing.T) {
+	fw := NewFnWorker(1, 10).WithPanicHandler(nil)
+	var count int
+	b := NewBatcher(2, time.Second, 10, func(items []interface{}) {
+		count += len(items)
+		if len(items) == 1 {
+			panic("test panic")
+		}
+	}).WithWorker(fw)
+	for i := 0; i < 5; i++ {
+		b.Add(i)
+	}
+	b.Stop()
+	fw.GracefulStop()
+	if count != 5 || fw.PanicCount() != 1 {
+		t.Fatalf("TestBatcherWithWorker err, want: [%v_%v], get: [%v_%v]", 5, 1, count, fw.PanicCount())
+	}
+}
@@ -0,0 +1,184 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// 合并执行的方法发生panic时，所有等待方收到的错误
+type PanicError struct {
+	// recover()的值
+	Value interface{}
+	// panic时的调用栈
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("fn panic: %v", e.Value)
+}
+
+// 正在执行的合并调用
+type flight struct {
+	done chan struct{}
+	once sync.Once
+	val  interface{}
+	err  error
+}
+
+// 缓存的执行结果
+type cachedResult struct {
+	val      interface{}
+	expireAt time.Time
+}
+
+// 合并调用器：相同key的并发调用只执行一次方法，所有调用方共享执行结果，
+// 可选择在异步工作者中执行方法，以及在ttl时长内缓存成功的执行结果
+type Coalescer struct {
+	mu sync.Mutex
+	// 正在执行的调用
+	flights map[string]*flight
+	// 缓存的执行结果，ttl<=0时不缓存
+	cache map[string]*cachedResult
+	ttl   time.Duration
+	// 上次清理过期结果的时间
+	lastSweep time.Time
+	// 执行方法的异步工作者，为nil时在第一个调用方的协程中执行
+	worker *FnWorker
+}
+
+// NewCoalescer 返回不缓存执行结果，在调用方协程中执行方法的合并调用器
+func NewCoalescer() *Coalescer {
+	return &Coalescer{
+		flights:   make(map[string]*flight),
+		cache:     make(map[string]*cachedResult),
+		lastSweep: time.Now(),
+	}
+}
+
+// WithWorker 设置执行方法的异步工作者w，使调用方可以通过DoCtx放弃等待而不中断执行，
+// w停止后未被执行的调用(例如：被StopNow丢弃)以ErrWorkerStopped结束
+// 注意：需要在调用前设置
+func (c *Coalescer) WithWorker(w *FnWorker) *Coalescer {
+	c.worker = w
+	return c
+}
+
+// WithTTL 设置成功执行结果的缓存时长ttl，ttl内相同key的调用直接返回缓存结果，<=0时不缓存
+// 注意：需要在调用前设置
+func (c *Coalescer) WithTTL(ttl time.Duration) *Coalescer {
+	c.ttl = ttl
+	return c
+}
+
+// Do 执行key对应的方法fn并返回其结果，相同key正在执行时等待并共享它的结果
+// 返回：执行结果、错误(fn发生panic时为*PanicError)和结果是否来自其他调用或者缓存
+func (c *Coalescer) Do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	return c.DoCtx(context.Background(), key, fn)
+}
+
+// DoCtx 同Do，ctx先结束时放弃等待并返回ctx.Err()，方法继续执行，其结果仍然会共享给其他调用方和缓存
+func (c *Coalescer) DoCtx(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	if fn == nil {
+		// 防止人为失误导致协程异常退出服务
+		return nil, errors.New("fn is nil"), false
+	}
+	c.mu.Lock()
+	if r, ok := c.cache[key]; ok {
+		if time.Now().Before(r.expireAt) {
+			c.mu.Unlock()
+			return r.val, nil, true
+		}
+		delete(c.cache, key)
+	}
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+		return c.wait(ctx, key, f, true)
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	if c.worker == nil {
+		c.run(key, f, fn, false)
+		return f.val, f.err, false
+	}
+	if err := c.worker.Serve(func() { c.run(key, f, fn, true) }); err != nil {
+		c.finish(key, f, nil, err)
+	}
+	return c.wait(ctx, key, f, false)
+}
+
+// wait 等待调用f完成，ctx先结束时返回ctx.Err()
+// 异步工作者的消费协程都已退出时f不会再被执行，此时以ErrWorkerStopped结束f
+func (c *Coalescer) wait(ctx context.Context, key string, f *flight, shared bool) (interface{}, error, bool) {
+	var workerDone <-chan struct{}
+	if c.worker != nil {
+		workerDone = c.worker.Done()
+	}
+	select {
+	case <-f.done:
+	case <-workerDone:
+		c.finish(key, f, nil, ErrWorkerStopped)
+	case <-ctx.Done():
+		return nil, ctx.Err(), shared
+	}
+	return f.val, f.err, shared
+}
+
+// run 执行方法fn并结束调用f，fn发生panic时以*PanicError结束，rePanic为true时继续panic交给异步工作者处理
+func (c *Coalescer) run(key string, f *flight, fn func() (interface{}, error), rePanic bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.finish(key, f, nil, &PanicError{Value: r, Stack: debug.Stack()})
+			if rePanic {
+				panic(r)
+			}
+		}
+	}()
+	val, err := fn()
+	c.finish(key, f, val, err)
+}
+
+// finish 设置调用f的结果并唤醒所有等待方，设置了ttl时缓存成功的结果，只有第一次结束生效
+func (c *Coalescer) finish(key string, f *flight, val interface{}, err error) {
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		c.mu.Lock()
+		// 调用期间可能被Forget
+		if c.flights[key] == f {
+			delete(c.flights, key)
+			if err == nil && c.ttl > 0 {
+				now := time.Now()
+				c.sweep(now)
+				c.cache[key] = &cachedResult{val: val, expireAt: now.Add(c.ttl)}
+			}
+		}
+		c.mu.Unlock()
+		close(f.done)
+	})
+}
+
+// sweep 距上次清理超过ttl时，删除所有过期的缓存结果，调用方需持有c.mu
+func (c *Coalescer) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+	for key, r := range c.cache {
+		if !now.Before(r.expireAt) {
+			delete(c.cache, key)
+		}
+	}
+}
+
+// Forget 删除key的缓存结果，并使之后的调用不再等待正在执行的调用
+func (c *Coalescer) Forget(key string) {
+	c.mu.Lock()
+	delete(c.cache, key)
+	delete(c.flights, key)
+	c.mu.Unlock()
+}
@@ -0,0 +1,142 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescerDo(t *testing.T) {
+	c := NewCoalescer()
+	var calls int32
+	startCh := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-startCh
+		return "v", nil
+	}
+	var wg sync.WaitGroup
+	var sharedCount int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := c.Do("k", fn)
+			if v != "v" || err != nil {
+				t.Errorf("TestCoalescerDo err, want: [%v_%v], get: [%v_%v]", "v", nil, v, err)
+			}
+			if shared {
+				atomic.AddInt32(&sharedCount, 1)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond * 20)
+	close(startCh)
+	wg.Wait()
+	if calls != 1 || sharedCount != 9 {
+		t.Fatalf("TestCoalescerDo err, want: [%v_%v], get: [%v_%v]", 1, 9, calls, sharedCount)
+	}
+	// 没有缓存时重新执行
+	c.Do("k", fn)
+	if calls != 2 {
+		t.Fatalf("TestCoalescerDo calls err, want: %v, get: %v", 2, calls)
+	}
+}
+
+func TestCoalescerTTL(t *testing.T) {
+	c := NewCoalescer().WithTTL(time.Millisecond * 30)
+	var calls int32
+	fn := func() (interface{}, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+	c.Do("k", fn)
+	if v, _, shared := c.Do("k", fn); v != int32(1) || !shared {
+		t.Fatalf("TestCoalescerTTL cache err, want: [%v_%v], get: [%v_%v]", 1, true, v, shared)
+	}
+	time.Sleep(time.Millisecond * 40)
+	if v, _, _ := c.Do("k", fn); v != int32(2) {
+		t.Fatalf("TestCoalescerTTL expire err, want: %v, get: %v", 2, v)
+	}
+	c.Forget("k")
+	if v, _, _ := c.Do("k", fn); v != int32(3) {
+		t.Fatalf("TestCoalescerTTL forget err, want: %v, get: %v", 3, v)
+	}
+	// 错误不缓存
+	testErr := errors.New("test err")
+	c.Do("e", func() (interface{}, error) { return nil, testErr })
+	if _, err, shared := c.Do("e", fn); err != nil || shared {
+		t.Fatalf("TestCoalescerTTL err cache err, get: [%v_%v]", err, shared)
+	}
+}
+
+func TestCoalescerPanicAndWorker(t *testing.T) {
+	fw := NewFnWorker(1, 1).WithPanicHandler(nil)
+	c := NewCoalescer().WithWorker(fw)
+	startCh := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-startCh
+		panic("test panic")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err, _ := c.Do("k", fn)
+			if pe, ok := err.(*PanicError); !ok || pe.Value != "test panic" {
+				t.Errorf("TestCoalescerPanicAndWorker err, get: %v", err)
+			}
+		}()
+	}
+	// 放弃等待不影响执行
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err, _ := c.DoCtx(ctx, "k", fn); err != context.DeadlineExceeded {
+		t.Fatalf("TestCoalescerPanicAndWorker ctx err, want: %v, get: %v", context.DeadlineExceeded, err)
+	}
+	close(startCh)
+	wg.Wait()
+	fw.GracefulStop()
+	if fw.PanicCount() != 1 {
+		t.Fatalf("TestCoalescerPanicAndWorker panic count err, want: %v, get: %v", 1, fw.PanicCount())
+	}
+	if _, err, _ := c.Do("k", fn); err != ErrWorkerStopped {
+		t.Fatalf("TestCoalescerPanicAndWorker stopped err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+}
+
+func TestCoalescerWorkerStopNow(t *testing.T) {
+	fw := NewFnWorker(1, 1)
+	c := NewCoalescer().WithWorker(fw)
+	endCh := make(chan int)
+	// 1个执行中，合并调用的方法在队列中
+	fw.Serve(func() { <-endCh })
+	errCh := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err, _ := c.Do("k", func() (interface{}, error) { return 1, nil })
+			errCh <- err
+		}()
+	}
+	time.Sleep(time.Millisecond * 20)
+	if fns := fw.StopNow(); len(fns) != 1 {
+		t.Fatalf("TestCoalescerWorkerStopNow dropped count err, want: %v, get: %v", 1, len(fns))
+	}
+	close(endCh)
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errCh:
+			if err != ErrWorkerStopped {
+				t.Fatalf("TestCoalescerWorkerStopNow err, want: %v, get: %v", ErrWorkerStopped, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("TestCoalescerWorkerStopNow flight is not finished")
+		}
+	}
+	if _, err, shared := c.Do("k", func() (interface{}, error) { return 1, nil }); err != ErrWorkerStopped || shared {
+		t.Fatalf("TestCoalescerWorkerStopNow do after stop err, get: [%v_%v]", err, shared)
+	}
+}
@@ -0,0 +1,299 @@
+package lib
+
+import (
+	"errors"
+	"github.com/happyxcj/golib/internal/timerpool"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 弹性协程池，消费信息为方法
+// 与FnWorker不同，所有消费协程共享一个有界队列，单个慢方法不会阻塞排在它后面的方法；
+// 消费协程个数在[minWorkers, maxWorkers]之间按需增加，空闲超过idleTimeout的协程退出
+type FnPool struct {
+	// 方法执行panic的次数，通过atomic访问，放在结构体开头以保证在32位平台上按8字节对齐
+	panicCount uint64
+	// 当前消费协程个数
+	workers int32
+	// 当前空闲的消费协程个数
+	idleWorkers int32
+	minWorkers  int32
+	maxWorkers  int32
+	// 消费协程的最大空闲时长
+	idleTimeout time.Duration
+	// 共享的待处理方法队列
+	fnCh chan func()
+	// 开始停止时关闭，通知阻塞的提交方返回失败
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// 保护提交与停止的并发，停止后不会再有方法入队
+	submitMu sync.RWMutex
+	// 没有提交方后关闭，通知所有消费协程处理完队列后退出
+	quitCh chan struct{}
+	// 保护消费协程个数的增减
+	mu sync.Mutex
+	// 等待所有协程完成退出
+	wg sync.WaitGroup
+	// 处理方法执行panic的方法，参数为recover()的值和panic时的调用栈
+	panicHandler func(r interface{}, stack []byte)
+}
+
+// NewFnPool 返回最少minWorkers个、最多maxWorkers个消费协程，队列长度为queueSize的协程池，
+// 超过minWorkers的协程空闲idleTimeout时长后退出
+func NewFnPool(minWorkers, maxWorkers, queueSize int, idleTimeout time.Duration) *FnPool {
+	if maxWorkers <= 0 {
+		maxWorkers = 1
+	}
+	if minWorkers > maxWorkers {
+		minWorkers = maxWorkers
+	}
+	p := &FnPool{
+		minWorkers:   int32(minWorkers),
+		maxWorkers:   int32(maxWorkers),
+		idleTimeout:  idleTimeout,
+		fnCh:         make(chan func(), queueSize),
+		stopCh:       make(chan struct{}),
+		quitCh:       make(chan struct{}),
+		panicHandler: logPanic,
+	}
+	p.mu.Lock()
+	for i := 0; i < minWorkers; i++ {
+		p.spawn()
+	}
+	p.mu.Unlock()
+	return p
+}
+
+// WithPanicHandler 设置处理方法执行panic的方法handler，参数为recover()的值和panic时的调用栈
+// 注意：需要在提交方法前设置
+func (p *FnPool) WithPanicHandler(handler func(r interface{}, stack []byte)) *FnPool {
+	p.panicHandler = handler
+	return p
+}
+
+// Workers 返回当前消费协程个数
+func (p *FnPool) Workers() int {
+	return int(atomic.LoadInt32(&p.workers))
+}
+
+// IdleWorkers 返回当前空闲的消费协程个数
+func (p *FnPool) IdleWorkers() int {
+	return int(atomic.LoadInt32(&p.idleWorkers))
+}
+
+// QueueLen 返回当前队列中待处理方法个数
+func (p *FnPool) QueueLen() int {
+	return len(p.fnCh)
+}
+
+// PanicCount 返回方法执行panic的次数
+func (p *FnPool) PanicCount() uint64 {
+	return atomic.LoadUint64(&p.panicCount)
+}
+
+// spawn 增加一个消费协程，调用方需持有p.mu且消费协程未开始退出
+func (p *FnPool) spawn() {
+	atomic.AddInt32(&p.workers, 1)
+	p.wg.Add(1)
+	go p.serveLoop()
+}
+
+// grow 没有空闲消费协程且未达到最大个数时增加一个消费协程
+func (p *FnPool) grow() {
+	if atomic.LoadInt32(&p.idleWorkers) > 0 || atomic.LoadInt32(&p.workers) >= p.maxWorkers {
+		return
+	}
+	p.mu.Lock()
+	if atomic.LoadInt32(&p.workers) < p.maxWorkers && !p.isQuit() {
+		p.spawn()
+	}
+	p.mu.Unlock()
+}
+
+// ensureWorker 方法入队后确保至少有一个消费协程，避免与空闲协程退出竞争导致方法无人处理
+func (p *FnPool) ensureWorker() {
+	if atomic.LoadInt32(&p.workers) > 0 {
+		return
+	}
+	p.mu.Lock()
+	if atomic.LoadInt32(&p.workers) == 0 && !p.isQuit() {
+		p.spawn()
+	}
+	p.mu.Unlock()
+}
+
+// retire 消费协程空闲超时后尝试退出，返回是否可以退出
+// 先减少协程个数再检查队列，与ensureWorker配合保证入队的方法总有协程处理
+func (p *FnPool) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if atomic.LoadInt32(&p.workers) <= p.minWorkers {
+		return false
+	}
+	atomic.AddInt32(&p.workers, -1)
+	if len(p.fnCh) > 0 {
+		atomic.AddInt32(&p.workers, 1)
+		return false
+	}
+	return true
+}
+
+func (p *FnPool) serveLoop() {
+	defer p.wg.Done()
+	t := timerpool.Acquire(p.idleTimeout)
+	defer timerpool.Release(t)
+	for {
+		atomic.AddInt32(&p.idleWorkers, 1)
+		select {
+		case fn := <-p.fnCh:
+			atomic.AddInt32(&p.idleWorkers, -1)
+			p.run(fn)
+		case <-t.C:
+			atomic.AddInt32(&p.idleWorkers, -1)
+			if p.retire() {
+				return
+			}
+		case <-p.quitCh:
+			atomic.AddInt32(&p.idleWorkers, -1)
+			p.drain()
+			atomic.AddInt32(&p.workers, -1)
+			return
+		}
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		t.Reset(p.idleTimeout)
+	}
+}
+
+// drain 处理完队列中剩余的方法
+func (p *FnPool) drain() {
+	for {
+		select {
+		case fn := <-p.fnCh:
+			p.run(fn)
+		default:
+			return
+		}
+	}
+}
+
+// run 执行方法fn，fn发生panic时恢复并交给panicHandler处理，使消费协程继续服务
+func (p *FnPool) run(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&p.panicCount, 1)
+			if p.panicHandler != nil {
+				p.panicHandler(r, debug.Stack())
+			}
+		}
+	}()
+	fn()
+}
+
+// isStopped 返回协程池是否已经停止
+func (p *FnPool) isStopped() bool {
+	select {
+	case <-p.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// isQuit 返回消费协程是否已经开始退出
+func (p *FnPool) isQuit() bool {
+	select {
+	case <-p.quitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// 服务指定方法fn直至成功，协程池停止时返回ErrWorkerStopped
+func (p *FnPool) Serve(fn func()) error {
+	if fn == nil {
+		return errors.New("fn is nil")
+	}
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.isStopped() {
+		return ErrWorkerStopped
+	}
+	p.grow()
+	select {
+	case p.fnCh <- fn:
+		p.ensureWorker()
+		return nil
+	case <-p.stopCh:
+		return ErrWorkerStopped
+	}
+}
+
+// 服务指定方法fn，最多等待timeout时长，超过则响应失败
+func (p *FnPool) ServeWithTimeout(fn func(), timeout time.Duration) error {
+	if fn == nil {
+		return errors.New("fn is nil")
+	}
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.isStopped() {
+		return ErrWorkerStopped
+	}
+	p.grow()
+	t := timerpool.Acquire(timeout)
+	defer timerpool.Release(t)
+	select {
+	case p.fnCh <- fn:
+		p.ensureWorker()
+		return nil
+	case <-t.C:
+		return errors.New("failed to serve the fn, caused by timeout")
+	case <-p.stopCh:
+		return ErrWorkerStopped
+	}
+}
+
+// 服务指定方法fn,失败时(队列满了)直接返回，不等待
+func (p *FnPool) ServeRightAway(fn func()) error {
+	if fn == nil {
+		return errors.New("fn is nil")
+	}
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.isStopped() {
+		return ErrWorkerStopped
+	}
+	p.grow()
+	select {
+	case p.fnCh <- fn:
+		p.ensureWorker()
+		return nil
+	default:
+		return errors.New("slow consumer detected")
+	}
+}
+
+// 优雅暂停协程池：有待处理消息则等处理完再退出，暂停后提交的方法返回ErrWorkerStopped
+// 返回：暂停前待处理消息数和处理后最终丢失消息数
+func (p *FnPool) GracefulStop() (int, int) {
+	pendingCount := len(p.fnCh)
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		// 等待正在进行的提交完成，此后不会再有方法入队
+		p.submitMu.Lock()
+		p.submitMu.Unlock()
+		// 持有p.mu关闭，保证此后不会再增加消费协程
+		p.mu.Lock()
+		close(p.quitCh)
+		p.mu.Unlock()
+	})
+	p.wg.Wait()
+	return pendingCount, len(p.fnCh)
+}
@@ -0,0 +1,159 @@
+package lib
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFnPoolServe(t *testing.T) {
+	p := NewFnPool(1, 4, 10, time.Millisecond*50)
+	if err := p.Serve(nil); err == nil {
+		t.Fatalf("TestFnPoolServe Serve nil err, get: %v", err)
+	}
+	var tmpCount int32
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func() {
+			p.Serve(func() {
+				atomic.AddInt32(&tmpCount, 1)
+				wg.Done()
+			})
+		}()
+	}
+	wg.Wait()
+	if tmpCount != 1000 {
+		t.Fatalf("TestFnPoolServe err, want: %v, get: %v", 1000, tmpCount)
+	}
+	if workers := p.Workers(); workers < 1 || workers > 4 {
+		t.Fatalf("TestFnPoolServe workers err, get: %v", workers)
+	}
+	// 空闲协程退出，保留最少协程个数
+	time.Sleep(time.Millisecond * 200)
+	if workers := p.Workers(); workers != 1 {
+		t.Fatalf("TestFnPoolServe shrink err, want: %v, get: %v", 1, workers)
+	}
+}
+
+func TestFnPoolSlowFn(t *testing.T) {
+	p := NewFnPool(0, 2, 10, time.Second)
+	endCh := make(chan struct{})
+	// 一个慢方法不会阻塞其他方法
+	p.Serve(func() {
+		<-endCh
+	})
+	done := make(chan struct{})
+	p.Serve(func() {
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("TestFnPoolSlowFn err, fn is blocked by slow fn")
+	}
+	close(endCh)
+}
+
+func TestFnPoolServeRightAway(t *testing.T) {
+	p := NewFnPool(2, 2, 10, time.Second)
+	if err := p.ServeRightAway(nil); err == nil {
+		t.Fatalf("TestFnPoolServeRightAway Serve nil err, get: %v", err)
+	}
+	var failedCount int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.ServeRightAway(func() {
+				select {}
+			})
+			if err != nil && err.Error() == "slow consumer detected" {
+				atomic.AddInt32(&failedCount, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	// 最多2个执行中，10个在队列中
+	if failedCount < 100-2-10 {
+		t.Fatalf("TestFnPoolServeRightAway err, want >= %v, get: %v", 100-2-10, failedCount)
+	}
+	// 确保队列已满
+	time.Sleep(time.Millisecond * 20)
+	for p.ServeRightAway(func() { select {} }) == nil {
+	}
+	err := p.ServeWithTimeout(func() {}, time.Millisecond*10)
+	if err == nil || err.Error() != "failed to serve the fn, caused by timeout" {
+		t.Fatalf("TestFnPoolServeRightAway timeout err, get: %v", err)
+	}
+}
+
+func TestFnPoolGracefulStop(t *testing.T) {
+	p := NewFnPool(1, 2, 20, time.Second)
+	endCh := make(chan int)
+	var doneCount int32
+	for i := 0; i < 22; i++ {
+		p.Serve(func() {
+			<-endCh
+			atomic.AddInt32(&doneCount, 1)
+		})
+	}
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		close(endCh)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	pendingCount, lostCount := p.GracefulStop()
+	if pendingCount != 20 || lostCount != 0 || doneCount != 22 {
+		t.Fatalf("TestFnPoolGracefulStop err, want: [%v_%v_%v], get: [%v_%v_%v]", 20, 0, 22, pendingCount, lostCount, doneCount)
+	}
+	if err := p.Serve(func() {}); err != ErrWorkerStopped {
+		t.Fatalf("TestFnPoolGracefulStop serve after stop err, get: %v", err)
+	}
+}
+
+func TestFnPoolServeRaceStop(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		p := NewFnPool(0, 2, 4, time.Millisecond)
+		var acceptedCount, ranCount int32
+		var wg sync.WaitGroup
+		var startOnce sync.Once
+		startedCh := make(chan struct{})
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				// 持续提交直至协程池停止
+				for {
+					var err error
+					fn := func() {
+						atomic.AddInt32(&ranCount, 1)
+						startOnce.Do(func() { close(startedCh) })
+					}
+					if j%2 == 0 {
+						err = p.ServeRightAway(fn)
+					} else {
+						err = p.Serve(fn)
+					}
+					if err == ErrWorkerStopped {
+						return
+					}
+					if err == nil {
+						atomic.AddInt32(&acceptedCount, 1)
+					}
+				}
+			}(j)
+		}
+		// 在提交方持续提交时停止
+		<-startedCh
+		time.Sleep(time.Millisecond)
+		_, lostCount := p.GracefulStop()
+		wg.Wait()
+		accepted, ran := atomic.LoadInt32(&acceptedCount), atomic.LoadInt32(&ranCount)
+		if accepted != ran+int32(lostCount) {
+			t.Fatalf("TestFnPoolServeRaceStop err, want accepted: %v, get ran: %v, lost: %v", accepted, ran, lostCount)
+		}
+	}
+}
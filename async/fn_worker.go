@@ -1,55 +1,268 @@
 package lib
 
 import (
+	"context"
 	"errors"
+	"github.com/happyxcj/golib/internal/timerpool"
+	"hash/fnv"
+	"log"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// TODO(xcj): 协程池避免无业务时协程冗余？
 // 异步工作者，消费信息为方法
+// 注意：消费协程个数固定，需要按需伸缩的协程池见FnPool
 type FnWorker struct {
-	// 消费协程个数
-	consumerCount int
-	fnChs          []chan func()
+	// 以下64位字段通过atomic访问，放在结构体开头以保证在32位平台上按8字节对齐
+
 	// 当前使用的消费者索引
 	currConsumerIndex uint64
+	// 方法执行panic的次数
+	panicCount uint64
+	// 方法执行返回错误的次数
+	errCount uint64
+	// 正在执行的方法个数
+	inFlightCount int64
+	// 执行完成的方法个数
+	completedCount uint64
+	// ServeRightAway因队列满了拒绝的方法个数
+	rejectedCount uint64
+	// ServeWithTimeout因超时失败的方法个数
+	timeoutCount uint64
+	// 放弃剩余方法后因等待限流令牌失败而放弃执行的方法个数
+	lostCount uint64
+	// 停止时每个优先级队列中丢失的方法个数，下标为Priority
+	laneLostCounts [priorityCount]uint64
+
+	// 消费协程个数
+	consumerCount int
+	// 普通优先级的队列，即lanes[PriorityNormal].fnChs
+	fnChs []chan func()
+	// 按照优先级区分的队列，不存在的优先级为nil
+	lanes [priorityCount]*lane
+	// 全局限流器，不限流时为nil
+	limiter *TokenBucket
+	// 按照key区分的限流器，不限流时为nil
+	keyLimiter *KeyedLimiter
+	// 处理方法排队时长的方法，不关注时可为nil
+	queueDurFn func(dur time.Duration)
+	// 处理方法执行时长的方法，不关注时可为nil
+	execDurFn func(dur time.Duration)
+	// 处理方法执行panic的方法，参数为recover()的值和panic时的调用栈
+	panicHandler func(r interface{}, stack []byte)
+	// 处理方法执行返回错误的方法，不关注时可为nil
+	errHandler func(err error)
 	// 等待所有协程完成退出
 	wg sync.WaitGroup
+	// 所有消费协程退出时关闭
+	doneCh chan struct{}
+	// 工作者上下文，通过ServeCtx提交的方法执行时会接收到它，工作者停止时被取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// 开始停止时关闭，通知阻塞的提交方返回失败
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// 保护提交与停止的并发，停止后不会再有方法入队
+	mu sync.RWMutex
+	// 没有提交方后关闭，通知消费协程处理完队列后退出
+	quitCh chan struct{}
+	// 是否放弃队列中剩余的方法，Stop超过期限或者StopNow时设置为1
+	aborted int32
+	// 放弃队列中剩余的方法时取消，用于中断等待限流令牌，GracefulStop不会取消它
+	abortCtx    context.Context
+	abortCancel context.CancelFunc
 }
 
+// 异步工作者或协程池停止后提交方法时返回的错误
+var ErrWorkerStopped = errors.New("worker is stopped")
+
+// NewFnWorker 返回consumerCount个消费者，每个消费者队列长度为chBufferSize的异步工作者
 func NewFnWorker(consumerCount, chBufferSize int) *FnWorker {
+	return NewPriorityFnWorker(consumerCount, LaneConfig{Priority: PriorityNormal, Size: chBufferSize})
+}
+
+func newFnWorker(consumerCount int) *FnWorker {
 	w := &FnWorker{
-		fnChs:          make([]chan func(), consumerCount),
 		consumerCount: consumerCount,
+		panicHandler:  logPanic,
+		stopCh:        make(chan struct{}),
+		quitCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
 	}
-	for i := 0; i < consumerCount; i++ {
-		fCh := make(chan func(), chBufferSize)
-		w.fnChs[i] = fCh
-		go w.serveLoop(fCh)
-	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.abortCtx, w.abortCancel = context.WithCancel(context.Background())
 	return w
 }
 
-func (w *FnWorker) serveLoop(fnCh chan func()) {
-	w.wg.Add(1)
+// start 启动所有消费协程
+func (w *FnWorker) start() {
+	w.wg.Add(w.consumerCount)
+	for i := 0; i < w.consumerCount; i++ {
+		go w.serveLoop(i)
+	}
+	go func() {
+		w.wg.Wait()
+		close(w.doneCh)
+	}()
+}
+
+// serveLoop 第i个消费者的消费循环
+// 每次从有剩余额度且有待处理方法的最高优先级队列中取出一个方法执行，并消耗该优先级的一个额度，
+// 所有有待处理方法的队列额度都用完时，每个优先级的额度重置为其weight，因此低优先级方法不会饿死
+func (w *FnWorker) serveLoop(i int) {
 	defer w.wg.Done()
+	chs := w.consumerChs(i)
+	var credits [priorityCount]int
+	w.resetCredits(&credits)
 	for {
-		fn := <-fnCh
-		if fn == nil {
-			// 退出信号
+		if w.isAborted() {
 			return
 		}
+		fn, p, ok := w.pollLanes(chs, &credits)
+		if !ok {
+			// 额度用完的队列中还有待处理方法，开始新一轮
+			w.resetCredits(&credits)
+			fn, p, ok = w.pollLanes(chs, &credits)
+		}
+		if !ok {
+			// 所有队列都没有待处理方法，阻塞等待
+			select {
+			case fn = <-chs[PriorityHigh]:
+				p = PriorityHigh
+			case fn = <-chs[PriorityNormal]:
+				p = PriorityNormal
+			case fn = <-chs[PriorityLow]:
+				p = PriorityLow
+			case <-w.quitCh:
+				// 退出信号
+				w.drainLanes(chs)
+				return
+			}
+			credits[p]--
+		}
+		w.run(fn)
+	}
+}
+
+// run 执行方法fn，fn发生panic时恢复并交给panicHandler处理，使消费协程继续服务
+func (w *FnWorker) run(fn func()) {
+	atomic.AddInt64(&w.inFlightCount, 1)
+	startT := time.Now()
+	defer func() {
+		atomic.AddInt64(&w.inFlightCount, -1)
+		atomic.AddUint64(&w.completedCount, 1)
+		if w.execDurFn != nil {
+			w.execDurFn(time.Since(startT))
+		}
+		if r := recover(); r != nil {
+			atomic.AddUint64(&w.panicCount, 1)
+			if w.panicHandler != nil {
+				w.panicHandler(r, debug.Stack())
+			}
+		}
+	}()
+	fn()
+}
+
+// 默认的panic处理方法：打印panic信息和调用栈
+func logPanic(r interface{}, stack []byte) {
+	log.Printf("FnWorker: fn panic: %v\n%s", r, stack)
+}
+
+// WithPanicHandler 设置处理方法执行panic的方法handler，参数为recover()的值和panic时的调用栈
+// 注意：需要在提交方法前设置
+func (w *FnWorker) WithPanicHandler(handler func(r interface{}, stack []byte)) *FnWorker {
+	w.panicHandler = handler
+	return w
+}
+
+// WithErrHandler 设置处理方法执行返回错误的方法handler，见WrapErrFn
+// 注意：需要在提交方法前设置
+func (w *FnWorker) WithErrHandler(handler func(err error)) *FnWorker {
+	w.errHandler = handler
+	return w
+}
+
+// WithQueueDurHandler 设置处理方法排队时长(从提交到开始执行)的方法handler，例如：记录直方图
+// 注意：需要在提交方法前设置
+func (w *FnWorker) WithQueueDurHandler(handler func(dur time.Duration)) *FnWorker {
+	w.queueDurFn = handler
+	return w
+}
+
+// WithExecDurHandler 设置处理方法执行时长的方法handler，例如：记录直方图
+// 注意：需要在提交方法前设置
+func (w *FnWorker) WithExecDurHandler(handler func(dur time.Duration)) *FnWorker {
+	w.execDurFn = handler
+	return w
+}
+
+// track 返回按照全局限流器限流的方法，设置了queueDurFn时，还会在执行前记录排队时长
+func (w *FnWorker) track(fn func()) func() {
+	fn = w.limit(fn)
+	if w.queueDurFn == nil {
+		return fn
+	}
+	enqueueT := time.Now()
+	return func() {
+		w.queueDurFn(time.Since(enqueueT))
 		fn()
 	}
 }
 
-// 获取下一个消费者，简单策略：平均分配
-func (w *FnWorker) findNextConsumer() chan func() {
+// PanicCount 返回方法执行panic的次数
+func (w *FnWorker) PanicCount() uint64 {
+	return atomic.LoadUint64(&w.panicCount)
+}
+
+// ErrCount 返回方法执行返回错误的次数
+func (w *FnWorker) ErrCount() uint64 {
+	return atomic.LoadUint64(&w.errCount)
+}
+
+// WrapErrFn 把返回错误的方法fn包装成可提交的方法，fn返回错误时计数并交给errHandler处理，
+// 可用于任意提交方式，例如：w.ServeRightAway(w.WrapErrFn(fn))，fn为nil时返回nil
+func (w *FnWorker) WrapErrFn(fn func() error) func() {
+	if fn == nil {
+		return nil
+	}
+	return func() {
+		if err := fn(); err != nil {
+			atomic.AddUint64(&w.errCount, 1)
+			if w.errHandler != nil {
+				w.errHandler(err)
+			}
+		}
+	}
+}
+
+// 获取下一个消费者索引，简单策略：平均分配
+func (w *FnWorker) nextConsumerIndex() uint64 {
 	next := atomic.AddUint64(&w.currConsumerIndex, 1)
-	index := next % uint64(w.consumerCount)
-	return w.fnChs[index]
+	return next % uint64(w.consumerCount)
+}
+
+// 获取普通优先级的下一个消费者
+func (w *FnWorker) findNextConsumer() chan func() {
+	return w.fnChs[w.nextConsumerIndex()]
+}
+
+// 根据key的哈希值获取固定的消费者，相同key总是使用相同的消费者
+func (w *FnWorker) findKeyConsumer(hash uint64) chan func() {
+	return w.fnChs[hash%uint64(w.consumerCount)]
+}
+
+// Context 返回工作者上下文，它在GracefulStop开始时、GracefulStopWithTimeout超时后、
+// Stop放弃等待时或者StopNow时被取消
+func (w *FnWorker) Context() context.Context {
+	return w.ctx
+}
+
+// Done 返回所有消费协程退出时关闭的通道，此后已经提交但还未执行的方法不会再被执行
+func (w *FnWorker) Done() <-chan struct{} {
+	return w.doneCh
 }
 
 // 服务指定方法fn直至成功
@@ -58,9 +271,28 @@ func (w *FnWorker) Serve(fn func()) error {
 		// 防止人为失误导致协程异常退出服务
 		return errors.New("fn is nil")
 	}
-	fCh := w.findNextConsumer()
-	fCh <- fn
-	return nil
+	return w.enqueue(w.findNextConsumer(), fn)
+}
+
+// 服务指定方法fn直至成功，相同key的方法由同一个消费者按照提交顺序执行，不同key的方法并行执行
+func (w *FnWorker) ServeKey(key string, fn func()) error {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return w.ServeKeyUint64(h.Sum64(), fn)
+}
+
+// 服务指定方法fn直至成功，规则同ServeKey，key为整数(例如：用户id)
+func (w *FnWorker) ServeKeyUint64(key uint64, fn func()) error {
+	if fn == nil {
+		// 防止人为失误导致协程异常退出服务
+		return errors.New("fn is nil")
+	}
+	return w.enqueue(w.findKeyConsumer(key), w.limitKey(key, fn))
+}
+
+// 服务返回错误的指定方法fn直至成功，fn返回的错误交给errHandler处理
+func (w *FnWorker) ServeErr(fn func() error) error {
+	return w.Serve(w.WrapErrFn(fn))
 }
 
 // 服务指定方法f，最多等待timeout时长，超过则响应失败
@@ -69,49 +301,195 @@ func (w *FnWorker) ServeWithTimeout(fn func(), timeout time.Duration) error {
 		// 防止人为失误导致协程异常退出服务
 		return errors.New("fn is nil")
 	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.isStopped() {
+		return ErrWorkerStopped
+	}
 	fCh := w.findNextConsumer()
-	t := acquireTimer(timeout)
-	defer releaseTimer(t)
+	t := timerpool.Acquire(timeout)
+	defer timerpool.Release(t)
 	select {
-	case fCh <- fn:
+	case fCh <- w.track(fn):
 	case <-t.C:
+		atomic.AddUint64(&w.timeoutCount, 1)
 		return errors.New("failed to serve the fn, caused by timeout")
+	case <-w.stopCh:
+		return ErrWorkerStopped
 	}
 	return nil
 }
 
+// 服务指定方法fn直至成功，ctx结束时放弃并返回ctx.Err()
+// fn执行时接收工作者上下文(见Context)，长时间运行的方法可据此在工作者停止时尽快退出
+func (w *FnWorker) ServeCtx(ctx context.Context, fn func(ctx context.Context)) error {
+	if fn == nil {
+		// 防止人为失误导致协程异常退出服务
+		return errors.New("fn is nil")
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.isStopped() {
+		return ErrWorkerStopped
+	}
+	fCh := w.findNextConsumer()
+	select {
+	case fCh <- w.track(func() { fn(w.ctx) }):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.stopCh:
+		return ErrWorkerStopped
+	}
+}
+
 // 服务指定方法,失败时(队列满了)直接返回，不等待
 func (w *FnWorker) ServeRightAway(fn func()) error {
 	if fn == nil {
 		// 防止人为失误导致协程异常退出服务
 		return errors.New("fn is nil")
 	}
-	fnCh := w.findNextConsumer()
+	return w.enqueueRightAway(w.findNextConsumer(), fn)
+}
+
+// enqueue 把方法fn放入队列fnCh直至成功，工作者停止时返回ErrWorkerStopped
+func (w *FnWorker) enqueue(fnCh chan func(), fn func()) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.isStopped() {
+		return ErrWorkerStopped
+	}
+	select {
+	case fnCh <- w.track(fn):
+		return nil
+	case <-w.stopCh:
+		return ErrWorkerStopped
+	}
+}
+
+// enqueueRightAway 把方法fn放入队列fnCh，队列满了时直接返回失败，工作者停止时返回ErrWorkerStopped
+func (w *FnWorker) enqueueRightAway(fnCh chan func(), fn func()) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.isStopped() {
+		return ErrWorkerStopped
+	}
 	select {
-	case fnCh <- fn:
+	case fnCh <- w.track(fn):
 		return nil
 	default:
+		atomic.AddUint64(&w.rejectedCount, 1)
 		return errors.New("slow consumer detected")
 	}
 }
 
+// 优雅暂停异步工作者：有待处理消息则等处理完再退出，开始暂停时取消工作者上下文
+// 返回：暂停前所有优先级待处理消息数和处理后最终丢失消息数
+func (w *FnWorker) GracefulStop() (int, int) {
+	w.cancel()
+	pendingCount, lostCount, _ := w.Stop(context.Background())
+	return pendingCount, lostCount
+}
 
-// 优雅暂停异步工作者：有待处理消息则等处理完再退出
+// 优雅暂停异步工作者：有待处理消息则等处理完再退出，等待超过timeout时长后取消工作者上下文
 // 返回：暂停前待处理消息数和处理后最终丢失消息数
-func (w *FnWorker) GracefulStop() (int, int) {
+func (w *FnWorker) GracefulStopWithTimeout(timeout time.Duration) (int, int) {
+	t := time.AfterFunc(timeout, w.cancel)
+	defer t.Stop()
+	pendingCount, lostCount, _ := w.Stop(context.Background())
+	return pendingCount, lostCount
+}
+
+// Stop 暂停异步工作者：有待处理消息则处理直至ctx结束，暂停后提交的方法返回ErrWorkerStopped
+// ctx先结束时取消工作者上下文，丢弃队列中剩余的方法并返回ctx.Err()，不再等待正在执行的方法
+// 返回：暂停前所有优先级待处理消息数和最终丢失消息数，每个优先级的个数见Stats的LanePending和LaneLost
+func (w *FnWorker) Stop(ctx context.Context) (int, int, error) {
 	// 待处理个数
-	pendingCount := 0
-	for _, fnCh := range w.fnChs {
-		pendingCount += len(fnCh)
+	pendingCount := w.pendingCount()
+	lostCount := atomic.LoadUint64(&w.lostCount)
+	w.beginStop()
+	select {
+	case <-w.doneCh:
+		w.cancel()
+		w.abortCancel()
+		for p := range w.lanes {
+			atomic.AddUint64(&w.laneLostCounts[p], uint64(w.LaneLen(Priority(p))))
+		}
+		// 最终丢失个数，包括等待限流令牌时放弃执行的方法
+		return pendingCount, w.pendingCount() + w.lostSince(lostCount), nil
+	case <-ctx.Done():
+		return pendingCount, len(w.abort()) + w.lostSince(lostCount), ctx.Err()
+	}
+}
+
+// StopNow 立即暂停异步工作者：取消工作者上下文，不再执行队列中剩余的方法，也不等待正在执行的方法
+// 返回：被丢弃的方法，调用方可以自行处理(例如：持久化后重试)
+func (w *FnWorker) StopNow() []func() {
+	w.beginStop()
+	return w.abort()
+}
+
+// beginStop 开始停止，此后提交的方法返回ErrWorkerStopped，消费协程处理完队列后退出
+func (w *FnWorker) beginStop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		// 等待正在进行的提交完成，此后不会再有方法入队
+		w.mu.Lock()
+		close(w.quitCh)
+		w.mu.Unlock()
+	})
+}
+
+// abort 取消工作者上下文，通知消费协程不再执行新的方法，返回从队列中取出的剩余方法
+func (w *FnWorker) abort() []func() {
+	atomic.StoreInt32(&w.aborted, 1)
+	w.abortCancel()
+	w.cancel()
+	var fns []func()
+	for p, l := range w.lanes {
+		if l == nil {
+			continue
+		}
+		n := len(fns)
+		for _, fnCh := range l.fnChs {
+			for len(fnCh) > 0 {
+				select {
+				case fn := <-fnCh:
+					fns = append(fns, fn)
+				default:
+				}
+			}
+		}
+		atomic.AddUint64(&w.laneLostCounts[p], uint64(len(fns)-n))
 	}
-	for _, fnCh := range w.fnChs {
-		fnCh <- nil
+	return fns
+}
+
+// lostSince 返回lostCount之后因等待限流令牌失败而放弃执行的方法个数
+func (w *FnWorker) lostSince(lostCount uint64) int {
+	return int(atomic.LoadUint64(&w.lostCount) - lostCount)
+}
+
+// isStopped 返回异步工作者是否已经开始停止
+func (w *FnWorker) isStopped() bool {
+	select {
+	case <-w.stopCh:
+		return true
+	default:
+		return false
 	}
-	w.wg.Wait()
-	// 最终丢失个数
-	lostCount := 0
-	for _, fnCh := range w.fnChs {
-		lostCount += len(fnCh)
+}
+
+// isAborted 返回是否放弃队列中剩余的方法
+func (w *FnWorker) isAborted() bool {
+	return atomic.LoadInt32(&w.aborted) == 1
+}
+
+// pendingCount 返回所有优先级队列中待处理方法个数
+func (w *FnWorker) pendingCount() int {
+	n := 0
+	for p := range w.lanes {
+		n += w.LaneLen(Priority(p))
 	}
-	return pendingCount, lostCount
+	return n
 }
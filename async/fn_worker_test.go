@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -108,6 +110,232 @@ func TestFnGracefulStop(t *testing.T) {
 	}
 }
 
+func TestFnServeCtx(t *testing.T) {
+	fw := NewFnWorker(1, 1)
+	if err := fw.ServeCtx(context.Background(), nil); err == nil {
+		t.Fatalf("TestFnServeCtx Serve nil err, get: %v", err)
+	}
+	// 1个执行中，1个在队列中
+	for i := 0; i < 2; i++ {
+		err := fw.ServeCtx(context.Background(), func(ctx context.Context) {
+			<-ctx.Done()
+		})
+		if err != nil {
+			t.Fatalf("TestFnServeCtx err: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err := fw.ServeCtx(ctx, func(ctx context.Context) {})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("TestFnServeCtx err, want: %v, get: %v", context.DeadlineExceeded, err)
+	}
+	// 任务在工作者上下文取消后退出
+	start := time.Now()
+	pendingCount, lostCount := fw.GracefulStopWithTimeout(time.Millisecond * 100)
+	if pendingCount != 1 || lostCount != 0 {
+		t.Fatalf("TestFnServeCtx err, want: [%v_%v], get: [%v_%v]", 1, 0, pendingCount, lostCount)
+	}
+	if dur := time.Since(start); dur < time.Millisecond*100 || dur > time.Second {
+		t.Fatalf("TestFnServeCtx stop dur err, get: %v", dur)
+	}
+	if fw.Context().Err() != context.Canceled {
+		t.Fatalf("TestFnServeCtx ctx err, want: %v, get: %v", context.Canceled, fw.Context().Err())
+	}
+}
+
+func TestFnPanicAndErr(t *testing.T) {
+	var panicVal interface{}
+	var errs []error
+	fw := NewFnWorker(1, 10).WithPanicHandler(func(r interface{}, stack []byte) {
+		panicVal = r
+	}).WithErrHandler(func(err error) {
+		errs = append(errs, err)
+	})
+	if err := fw.ServeErr(nil); err == nil {
+		t.Fatalf("TestFnPanicAndErr Serve nil err, get: %v", err)
+	}
+	fw.Serve(func() {
+		panic("test panic")
+	})
+	fw.ServeErr(func() error {
+		return errors.New("test err")
+	})
+	fw.ServeRightAway(fw.WrapErrFn(func() error {
+		return nil
+	}))
+	// panic后消费协程继续服务
+	done := make(chan struct{})
+	fw.Serve(func() {
+		close(done)
+	})
+	<-done
+	if panicVal != "test panic" || fw.PanicCount() != 1 {
+		t.Fatalf("TestFnPanicAndErr panic err, get: %v_%v", panicVal, fw.PanicCount())
+	}
+	if len(errs) != 1 || errs[0].Error() != "test err" || fw.ErrCount() != 1 {
+		t.Fatalf("TestFnPanicAndErr err, get: %v_%v", errs, fw.ErrCount())
+	}
+}
+
+func TestFnServeKey(t *testing.T) {
+	fw := NewFnWorker(4, 10)
+	if err := fw.ServeKey("a", nil); err == nil {
+		t.Fatalf("TestFnServeKey Serve nil err, get: %v", err)
+	}
+	var mu sync.Mutex
+	results := make(map[string][]int)
+	var wg sync.WaitGroup
+	keys := []string{"a", "b", "c", "d", "e"}
+	for i := 0; i < 100; i++ {
+		for _, key := range keys {
+			i, key := i, key
+			wg.Add(1)
+			fw.ServeKey(key, func() {
+				defer wg.Done()
+				mu.Lock()
+				results[key] = append(results[key], i)
+				mu.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+	for _, key := range keys {
+		for i, v := range results[key] {
+			if i != v {
+				t.Fatalf("TestFnServeKey key '%v' order err, want: %v, get: %v", key, i, v)
+			}
+		}
+	}
+	if fw.findKeyConsumer(7) != fw.findKeyConsumer(7) || fw.findKeyConsumer(7) == fw.findKeyConsumer(8) {
+		t.Fatalf("TestFnServeKey consumer err")
+	}
+}
+
+func TestFnStop(t *testing.T) {
+	fw := NewFnWorker(1, 10)
+	endCh := make(chan int, 0)
+	var doneCount int32
+	// 1个执行中，5个在队列中
+	for i := 0; i < 6; i++ {
+		err := fw.Serve(func() {
+			<-endCh
+			atomic.AddInt32(&doneCount, 1)
+		})
+		if err != nil {
+			t.Fatalf("TestFnStop err: %v", err)
+		}
+	}
+	time.Sleep(time.Millisecond * 20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	pendingCount, lostCount, err := fw.Stop(ctx)
+	if pendingCount != 5 || lostCount != 5 || err != context.DeadlineExceeded {
+		t.Fatalf("TestFnStop err, want: [%v_%v_%v], get: [%v_%v_%v]",
+			5, 5, context.DeadlineExceeded, pendingCount, lostCount, err)
+	}
+	if dur := time.Since(start); dur > time.Second {
+		t.Fatalf("TestFnStop stop dur err, get: %v", dur)
+	}
+	if fw.Context().Err() != context.Canceled {
+		t.Fatalf("TestFnStop ctx err, want: %v, get: %v", context.Canceled, fw.Context().Err())
+	}
+	// 停止后提交的方法都返回ErrWorkerStopped
+	if err := fw.Serve(func() {}); err != ErrWorkerStopped {
+		t.Fatalf("TestFnStop Serve err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+	if err := fw.ServeRightAway(func() {}); err != ErrWorkerStopped {
+		t.Fatalf("TestFnStop ServeRightAway err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+	if err := fw.ServeWithTimeout(func() {}, time.Second); err != ErrWorkerStopped {
+		t.Fatalf("TestFnStop ServeWithTimeout err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+	if _, err := fw.Submit(func() (interface{}, error) { return nil, nil }).Get(context.Background()); err != ErrWorkerStopped {
+		t.Fatalf("TestFnStop Submit err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+	// 被放弃的方法不再执行
+	close(endCh)
+	time.Sleep(time.Millisecond * 20)
+	if n := atomic.LoadInt32(&doneCount); n != 1 {
+		t.Fatalf("TestFnStop done count err, want: %v, get: %v", 1, n)
+	}
+}
+
+func TestFnStopNow(t *testing.T) {
+	fw := NewFnWorker(1, 10)
+	endCh := make(chan int, 0)
+	blockedCh := make(chan error, 1)
+	// 1个执行中，10个在队列中，1个阻塞在提交中
+	for i := 0; i < 11; i++ {
+		fw.Serve(func() { <-endCh })
+	}
+	go func() {
+		blockedCh <- fw.Serve(func() {})
+	}()
+	time.Sleep(time.Millisecond * 20)
+	fns := fw.StopNow()
+	if len(fns) != 10 {
+		t.Fatalf("TestFnStopNow dropped count err, want: %v, get: %v", 10, len(fns))
+	}
+	select {
+	case err := <-blockedCh:
+		if err != ErrWorkerStopped {
+			t.Fatalf("TestFnStopNow blocked Serve err, want: %v, get: %v", ErrWorkerStopped, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("TestFnStopNow blocked Serve is not released")
+	}
+	close(endCh)
+	if pendingCount, lostCount := fw.GracefulStop(); pendingCount != 0 || lostCount != 0 {
+		t.Fatalf("TestFnStopNow err, want: [%v_%v], get: [%v_%v]", 0, 0, pendingCount, lostCount)
+	}
+}
+
+func TestFnServeRaceStop(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		fw := NewFnWorker(2, 4)
+		var acceptedCount, ranCount int32
+		var wg sync.WaitGroup
+		var startOnce sync.Once
+		startedCh := make(chan struct{})
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				// 持续提交直至异步工作者停止
+				for {
+					var err error
+					fn := func() {
+						atomic.AddInt32(&ranCount, 1)
+						startOnce.Do(func() { close(startedCh) })
+					}
+					if j%2 == 0 {
+						err = fw.ServeRightAway(fn)
+					} else {
+						err = fw.Serve(fn)
+					}
+					if err == ErrWorkerStopped {
+						return
+					}
+					if err == nil {
+						atomic.AddInt32(&acceptedCount, 1)
+					}
+				}
+			}(j)
+		}
+		// 在提交方持续提交时停止
+		<-startedCh
+		time.Sleep(time.Millisecond)
+		_, lostCount := fw.GracefulStop()
+		wg.Wait()
+		accepted, ran := atomic.LoadInt32(&acceptedCount), atomic.LoadInt32(&ranCount)
+		if accepted != ran+int32(lostCount) {
+			t.Fatalf("TestFnServeRaceStop err, want accepted: %v, get ran: %v, lost: %v", accepted, ran, lostCount)
+		}
+	}
+}
+
 func BenchmarkServeF(b *testing.B) {
 	fw := NewFnWorker(50, 200)
 	var wg sync.WaitGroup
@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// 方法开始执行前被取消时，Future.Get返回的错误
+var ErrFutureCancelled = errors.New("future is cancelled")
+
+// Future 的状态
+const (
+	futurePending int32 = iota
+	futureRunning
+	futureDone
+	futureCancelled
+)
+
+// Future 是提交到FnWorker的方法的执行结果
+type Future struct {
+	state int32
+	done  chan struct{}
+	val   interface{}
+	err   error
+	// 执行方法的异步工作者的Done()，它关闭后还未开始执行的方法不会再被执行
+	workerDone <-chan struct{}
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Done 返回一个在方法执行完成或者被取消后关闭的通道
+// 注意：方法被停止的异步工作者丢弃时，该通道在Get、WaitAll或者WaitAny检测到后才关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get 等待方法执行完成并返回其结果，ctx先结束时返回ctx.Err()
+// 方法开始执行前被取消时返回ErrFutureCancelled，被停止的异步工作者丢弃时返回ErrWorkerStopped
+func (f *Future) Get(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+	case <-f.workerDone:
+		f.abandon()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return f.val, f.err
+}
+
+// Cancel 取消还未开始执行的方法，返回是否取消成功，方法已经开始执行或者执行完成时返回false
+func (f *Future) Cancel() bool {
+	if !atomic.CompareAndSwapInt32(&f.state, futurePending, futureCancelled) {
+		return false
+	}
+	f.err = ErrFutureCancelled
+	close(f.done)
+	return true
+}
+
+// IsCancelled 返回方法是否在开始执行前被取消
+func (f *Future) IsCancelled() bool {
+	return atomic.LoadInt32(&f.state) == futureCancelled
+}
+
+// abandon 异步工作者的消费协程都已退出时，以ErrWorkerStopped结束还未开始执行的方法，并等待Future结束
+func (f *Future) abandon() {
+	if atomic.CompareAndSwapInt32(&f.state, futurePending, futureDone) {
+		f.err = ErrWorkerStopped
+		close(f.done)
+	}
+	<-f.done
+}
+
+// complete 设置方法的执行结果
+func (f *Future) complete(val interface{}, err error) {
+	f.val, f.err = val, err
+	atomic.StoreInt32(&f.state, futureDone)
+	close(f.done)
+}
+
+// wrap 返回执行fn并设置执行结果的方法，已被取消时不执行fn
+// fn发生panic时以错误结束Future，并继续panic交给FnWorker处理
+func (f *Future) wrap(fn func() (interface{}, error)) func() {
+	return func() {
+		if !atomic.CompareAndSwapInt32(&f.state, futurePending, futureRunning) {
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				f.complete(nil, fmt.Errorf("fn panic: %v", r))
+				panic(r)
+			}
+		}()
+		f.complete(fn())
+	}
+}
+
+// Submit 服务指定方法fn直至成功，返回fn的执行结果Future
+// fn为nil时返回的Future以错误结束
+func (w *FnWorker) Submit(fn func() (interface{}, error)) *Future {
+	f := newFuture()
+	if fn == nil {
+		f.complete(nil, errors.New("fn is nil"))
+		return f
+	}
+	f.workerDone = w.Done()
+	if err := w.Serve(f.wrap(fn)); err != nil {
+		f.complete(nil, err)
+	}
+	return f
+}
+
+// WaitAll 等待所有fs执行完成，返回按顺序排列的执行结果和遇到的第一个错误，ctx先结束时返回ctx.Err()
+func WaitAll(ctx context.Context, fs ...*Future) ([]interface{}, error) {
+	vals := make([]interface{}, len(fs))
+	var firstErr error
+	for i, f := range fs {
+		val, err := f.Get(ctx)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		vals[i] = val
+	}
+	return vals, firstErr
+}
+
+// WaitAny 等待fs中任意一个执行完成，返回它的索引和执行结果，ctx先结束时返回-1和ctx.Err()
+func WaitAny(ctx context.Context, fs ...*Future) (int, interface{}, error) {
+	// 前len(fs)个为每个Future的done，其次len(fs)个为每个Future的workerDone，最后一个为ctx.Done()
+	cases := make([]reflect.SelectCase, 2*len(fs)+1)
+	for i, f := range fs {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.done)}
+		cases[len(fs)+i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.workerDone)}
+	}
+	cases[2*len(fs)] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == 2*len(fs) {
+		return -1, nil, ctx.Err()
+	}
+	if chosen >= len(fs) {
+		chosen -= len(fs)
+		fs[chosen].abandon()
+	}
+	return chosen, fs[chosen].val, fs[chosen].err
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFutureGet(t *testing.T) {
+	fw := NewFnWorker(2, 10).WithPanicHandler(nil)
+	f := fw.Submit(func() (interface{}, error) {
+		return 1, nil
+	})
+	val, err := f.Get(context.Background())
+	if val != 1 || err != nil {
+		t.Fatalf("TestFutureGet err, want: [%v_%v], get: [%v_%v]", 1, nil, val, err)
+	}
+	if _, err := fw.Submit(nil).Get(context.Background()); err == nil {
+		t.Fatalf("TestFutureGet Submit nil err, get: %v", err)
+	}
+	// panic时以错误结束
+	f = fw.Submit(func() (interface{}, error) {
+		panic("test panic")
+	})
+	if _, err := f.Get(context.Background()); err == nil || err.Error() != "fn panic: test panic" {
+		t.Fatalf("TestFutureGet panic err, get: %v", err)
+	}
+	// 超时
+	f = fw.Submit(func() (interface{}, error) {
+		time.Sleep(time.Millisecond * 100)
+		return nil, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err := f.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("TestFutureGet timeout err, want: %v, get: %v", context.DeadlineExceeded, err)
+	}
+	<-f.Done()
+}
+
+func TestFutureCancel(t *testing.T) {
+	fw := NewFnWorker(1, 10)
+	endCh := make(chan struct{})
+	running := fw.Submit(func() (interface{}, error) {
+		<-endCh
+		return nil, nil
+	})
+	ran := false
+	pending := fw.Submit(func() (interface{}, error) {
+		ran = true
+		return nil, nil
+	})
+	if !pending.Cancel() || !pending.IsCancelled() {
+		t.Fatalf("TestFutureCancel cancel pending err")
+	}
+	if _, err := pending.Get(context.Background()); err != ErrFutureCancelled {
+		t.Fatalf("TestFutureCancel err, want: %v, get: %v", ErrFutureCancelled, err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	if running.Cancel() {
+		t.Fatalf("TestFutureCancel cancel running err")
+	}
+	close(endCh)
+	fw.GracefulStop()
+	if ran {
+		t.Fatalf("TestFutureCancel cancelled fn is executed")
+	}
+}
+
+func TestWaitAllAndAny(t *testing.T) {
+	fw := NewFnWorker(3, 10)
+	newFn := func(val int, delay time.Duration, err error) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			time.Sleep(delay)
+			return val, err
+		}
+	}
+	testErr := errors.New("test err")
+	f1 := fw.Submit(newFn(1, time.Millisecond*50, nil))
+	f2 := fw.Submit(newFn(2, time.Millisecond*10, nil))
+	f3 := fw.Submit(newFn(3, time.Millisecond*30, testErr))
+
+	i, val, err := WaitAny(context.Background(), f1, f2, f3)
+	if i != 1 || val != 2 || err != nil {
+		t.Fatalf("TestWaitAllAndAny WaitAny err, get: [%v_%v_%v]", i, val, err)
+	}
+	vals, err := WaitAll(context.Background(), f1, f2, f3)
+	if err != testErr || vals[0] != 1 || vals[1] != 2 || vals[2] != 3 {
+		t.Fatalf("TestWaitAllAndAny WaitAll err, get: [%v_%v]", vals, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f4 := fw.Submit(newFn(4, time.Millisecond*50, nil))
+	if i, _, err := WaitAny(ctx, f4); i != -1 || err != context.Canceled {
+		t.Fatalf("TestWaitAllAndAny WaitAny ctx err, get: [%v_%v]", i, err)
+	}
+	if _, err := WaitAll(ctx, f4); err != context.Canceled {
+		t.Fatalf("TestWaitAllAndAny WaitAll ctx err, get: %v", err)
+	}
+}
+
+func TestFutureWorkerStopped(t *testing.T) {
+	newBlockedWorker := func() (*FnWorker, chan struct{}) {
+		fw := NewFnWorker(1, 10)
+		startCh, endCh := make(chan struct{}), make(chan struct{})
+		fw.Serve(func() {
+			close(startCh)
+			<-endCh
+		})
+		<-startCh
+		return fw, endCh
+	}
+	fn := func() (interface{}, error) { return 1, nil }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// StopNow丢弃队列中的方法
+	fw, endCh := newBlockedWorker()
+	f := fw.Submit(fn)
+	fw.StopNow()
+	close(endCh)
+	if _, err := f.Get(ctx); err != ErrWorkerStopped {
+		t.Fatalf("TestFutureWorkerStopped StopNow err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+
+	// Stop超过期限丢弃队列中的方法
+	fw, endCh = newBlockedWorker()
+	f1, f2 := fw.Submit(fn), fw.Submit(fn)
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer stopCancel()
+	fw.Stop(stopCtx)
+	close(endCh)
+	if _, err := WaitAll(ctx, f1, f2); err != ErrWorkerStopped {
+		t.Fatalf("TestFutureWorkerStopped Stop err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+
+	// 限流器放弃等待令牌的方法
+	fw = NewFnWorker(1, 10).WithRateLimit(1, 1)
+	f1 = fw.Submit(fn)
+	f2 = fw.Submit(fn)
+	if val, err := f1.Get(ctx); val != 1 || err != nil {
+		t.Fatalf("TestFutureWorkerStopped first err, want: [%v_%v], get: [%v_%v]", 1, nil, val, err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	fw.StopNow()
+	if i, _, err := WaitAny(ctx, f2); i != 0 || err != ErrWorkerStopped {
+		t.Fatalf("TestFutureWorkerStopped rate limit err, want: [%v_%v], get: [%v_%v]", 0, ErrWorkerStopped, i, err)
+	}
+	if fw.Stats().Lost != 1 {
+		t.Fatalf("TestFutureWorkerStopped lost err, want: %v, get: %v", 1, fw.Stats().Lost)
+	}
+}
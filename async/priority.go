@@ -0,0 +1,190 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 方法的优先级
+type Priority int
+
+const (
+	// 低优先级，例如：统计数据上报等批量任务
+	PriorityLow Priority = iota
+	// 普通优先级，Serve等不指定优先级的提交方式使用它
+	PriorityNormal
+	// 高优先级，例如：面向用户的通知
+	PriorityHigh
+	// 优先级个数
+	priorityCount
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("unknown priority: %d", int(p))
+	}
+}
+
+// 优先级通道配置
+type LaneConfig struct {
+	// 优先级
+	Priority Priority
+	// 每个消费者在该优先级的队列长度
+	Size int
+	// 每轮最多从该优先级取出的方法个数，避免低优先级方法饿死，<=0时使用该优先级的默认权重
+	Weight int
+}
+
+// 每个优先级的默认权重，高优先级每轮取出的方法最多
+var defaultLaneWeights = [priorityCount]int{
+	PriorityLow:    1,
+	PriorityNormal: 2,
+	PriorityHigh:   4,
+}
+
+// 优先级通道，每个消费者对应一个队列
+type lane struct {
+	fnChs  []chan func()
+	weight int
+}
+
+// NewPriorityFnWorker 返回按照优先级消费的异步工作者，lanes必须包含PriorityNormal
+// 每个消费者每轮最多从每个优先级的队列中取出LaneConfig.Weight个方法执行，同一轮中高优先级方法总是先执行，
+// 低优先级方法也不会饿死
+func NewPriorityFnWorker(consumerCount int, lanes ...LaneConfig) *FnWorker {
+	w := newFnWorker(consumerCount)
+	for _, cfg := range lanes {
+		if cfg.Priority < PriorityLow || cfg.Priority >= priorityCount {
+			panic(fmt.Sprintf("invalid priority: %d", int(cfg.Priority)))
+		}
+		if w.lanes[cfg.Priority] != nil {
+			panic(fmt.Sprintf("duplicate priority lane: %v", cfg.Priority))
+		}
+		l := &lane{
+			fnChs:  make([]chan func(), consumerCount),
+			weight: cfg.Weight,
+		}
+		if l.weight <= 0 {
+			l.weight = defaultLaneWeights[cfg.Priority]
+		}
+		for i := range l.fnChs {
+			l.fnChs[i] = make(chan func(), cfg.Size)
+		}
+		w.lanes[cfg.Priority] = l
+	}
+	if w.lanes[PriorityNormal] == nil {
+		panic("priority lane 'normal' is required")
+	}
+	w.fnChs = w.lanes[PriorityNormal].fnChs
+	w.start()
+	return w
+}
+
+// 获取指定优先级p的下一个消费者，优先级通道不存在时返回错误
+func (w *FnWorker) findNextLaneConsumer(p Priority) (chan func(), error) {
+	if p < PriorityLow || p >= priorityCount || w.lanes[p] == nil {
+		return nil, errors.New("priority lane is not configured")
+	}
+	if p == PriorityNormal {
+		return w.findNextConsumer(), nil
+	}
+	return w.lanes[p].fnChs[w.nextConsumerIndex()], nil
+}
+
+// 按照优先级p服务指定方法fn直至成功
+func (w *FnWorker) ServePriority(p Priority, fn func()) error {
+	if fn == nil {
+		// 防止人为失误导致协程异常退出服务
+		return errors.New("fn is nil")
+	}
+	fnCh, err := w.findNextLaneConsumer(p)
+	if err != nil {
+		return err
+	}
+	return w.enqueue(fnCh, fn)
+}
+
+// 按照优先级p服务指定方法fn,失败时(该优先级队列满了)直接返回，不等待
+func (w *FnWorker) ServePriorityRightAway(p Priority, fn func()) error {
+	if fn == nil {
+		// 防止人为失误导致协程异常退出服务
+		return errors.New("fn is nil")
+	}
+	fnCh, err := w.findNextLaneConsumer(p)
+	if err != nil {
+		return err
+	}
+	return w.enqueueRightAway(fnCh, fn)
+}
+
+// LaneLen 返回优先级p所有队列中待处理方法个数，优先级通道不存在时返回0
+func (w *FnWorker) LaneLen(p Priority) int {
+	if p < PriorityLow || p >= priorityCount || w.lanes[p] == nil {
+		return 0
+	}
+	n := 0
+	for _, fnCh := range w.lanes[p].fnChs {
+		n += len(fnCh)
+	}
+	return n
+}
+
+// consumerChs 返回第i个消费者在每个优先级的队列，不存在的优先级为nil
+func (w *FnWorker) consumerChs(i int) [priorityCount]chan func() {
+	var chs [priorityCount]chan func()
+	for p, l := range w.lanes {
+		if l != nil {
+			chs[p] = l.fnChs[i]
+		}
+	}
+	return chs
+}
+
+// resetCredits 重置每个优先级的额度为其weight
+func (w *FnWorker) resetCredits(credits *[priorityCount]int) {
+	for p, l := range w.lanes {
+		if l != nil {
+			credits[p] = l.weight
+		}
+	}
+}
+
+// pollLanes 不阻塞地从有剩余额度的最高优先级队列中取出一个方法，并消耗该优先级的一个额度
+// 返回方法及其优先级，所有有剩余额度的队列都为空时返回false
+func (w *FnWorker) pollLanes(chs [priorityCount]chan func(), credits *[priorityCount]int) (func(), Priority, bool) {
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if chs[p] == nil || credits[p] <= 0 {
+			continue
+		}
+		select {
+		case fn := <-chs[p]:
+			credits[p]--
+			return fn, p, true
+		default:
+		}
+	}
+	return nil, 0, false
+}
+
+// drainLanes 按照优先级从高到低执行完所有队列中剩余的方法
+func (w *FnWorker) drainLanes(chs [priorityCount]chan func()) {
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if chs[p] == nil {
+			continue
+		}
+		for len(chs[p]) > 0 && !w.isAborted() {
+			select {
+			case fn := <-chs[p]:
+				w.run(fn)
+			default:
+			}
+		}
+	}
+}
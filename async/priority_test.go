@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFnServePriority(t *testing.T) {
+	fw := NewPriorityFnWorker(1,
+		LaneConfig{Priority: PriorityHigh, Size: 10, Weight: 2},
+		LaneConfig{Priority: PriorityNormal, Size: 10, Weight: 1},
+		LaneConfig{Priority: PriorityLow, Size: 3, Weight: 1},
+	)
+	if err := fw.ServePriority(PriorityHigh, nil); err == nil {
+		t.Fatalf("TestFnServePriority Serve nil err, get: %v", err)
+	}
+	var mu sync.Mutex
+	var order []string
+	newFn := func(name string) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+	// 阻塞消费者，使后续方法都在队列中
+	startCh, endCh := make(chan struct{}), make(chan struct{})
+	fw.Serve(func() {
+		close(startCh)
+		<-endCh
+	})
+	<-startCh
+	for i := 1; i <= 3; i++ {
+		fw.ServePriority(PriorityLow, newFn(fmt.Sprintf("L%d", i)))
+		fw.Serve(newFn(fmt.Sprintf("N%d", i)))
+	}
+	for i := 1; i <= 5; i++ {
+		fw.ServePriority(PriorityHigh, newFn(fmt.Sprintf("H%d", i)))
+	}
+	// 低优先级队列已满
+	if err := fw.ServePriorityRightAway(PriorityLow, func() {}); err == nil || err.Error() != "slow consumer detected" {
+		t.Fatalf("TestFnServePriority lane full err, get: %v", err)
+	}
+	if n := fw.LaneLen(PriorityHigh); n != 5 {
+		t.Fatalf("TestFnServePriority LaneLen err, want: %v, get: %v", 5, n)
+	}
+	close(endCh)
+	pendingCount, lostCount := fw.GracefulStop()
+	if pendingCount != 11 || lostCount != 0 {
+		t.Fatalf("TestFnServePriority stop err, want: [%v_%v], get: [%v_%v]", 11, 0, pendingCount, lostCount)
+	}
+	// 阻塞的方法消耗了本轮普通优先级的额度
+	want := "[H1 H2 L1 H3 H4 N1 L2 H5 N2 L3 N3]"
+	if get := fmt.Sprint(order); get != want {
+		t.Fatalf("TestFnServePriority order err, want: %v, get: %v", want, get)
+	}
+}
+
+func TestFnServePriorityDefaultWeight(t *testing.T) {
+	fw := NewPriorityFnWorker(1,
+		LaneConfig{Priority: PriorityHigh, Size: 10},
+		LaneConfig{Priority: PriorityNormal, Size: 10},
+		LaneConfig{Priority: PriorityLow, Size: 10},
+	)
+	var mu sync.Mutex
+	var order []string
+	newFn := func(name string) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+	startCh, endCh := make(chan struct{}), make(chan struct{})
+	fw.Serve(func() {
+		close(startCh)
+		<-endCh
+	})
+	<-startCh
+	for i := 1; i <= 6; i++ {
+		fw.ServePriority(PriorityLow, newFn(fmt.Sprintf("L%d", i)))
+		fw.Serve(newFn(fmt.Sprintf("N%d", i)))
+		fw.ServePriority(PriorityHigh, newFn(fmt.Sprintf("H%d", i)))
+	}
+	close(endCh)
+	fw.GracefulStop()
+	// 默认每轮高、普通、低优先级分别最多取出4、2、1个方法，阻塞的方法消耗了本轮普通优先级的1个额度
+	want := "[H1 H2 H3 H4 N1 L1 H5 H6 N2 N3 L2 N4 N5 L3 N6 L4 L5 L6]"
+	if get := fmt.Sprint(order); get != want {
+		t.Fatalf("TestFnServePriorityDefaultWeight order err, want: %v, get: %v", want, get)
+	}
+}
+
+func TestFnPriorityLaneStats(t *testing.T) {
+	fw := NewPriorityFnWorker(1,
+		LaneConfig{Priority: PriorityHigh, Size: 5},
+		LaneConfig{Priority: PriorityNormal, Size: 5},
+		LaneConfig{Priority: PriorityLow, Size: 5},
+	)
+	startCh, endCh := make(chan struct{}), make(chan struct{})
+	fw.Serve(func() {
+		close(startCh)
+		<-endCh
+	})
+	<-startCh
+	for i := 0; i < 3; i++ {
+		fw.ServePriority(PriorityLow, func() {})
+	}
+	fw.Serve(func() {})
+	for i := 0; i < 2; i++ {
+		fw.ServePriority(PriorityHigh, func() {})
+	}
+	if stats := fw.Stats(); stats.LanePending != [priorityCount]int{3, 1, 2} {
+		t.Fatalf("TestFnPriorityLaneStats pending err, want: %v, get: %v", [priorityCount]int{3, 1, 2}, stats.LanePending)
+	}
+	if fns := fw.StopNow(); len(fns) != 6 {
+		t.Fatalf("TestFnPriorityLaneStats dropped count err, want: %v, get: %v", 6, len(fns))
+	}
+	close(endCh)
+	fw.GracefulStop()
+	stats := fw.Stats()
+	if stats.LanePending != [priorityCount]int{} || stats.LaneLost != [priorityCount]uint64{3, 1, 2} {
+		t.Fatalf("TestFnPriorityLaneStats lost err, want: %v, get: %v", [priorityCount]uint64{3, 1, 2}, stats.LaneLost)
+	}
+}
+
+func TestFnServePriorityNotConfigured(t *testing.T) {
+	fw := NewFnWorker(1, 1)
+	if err := fw.ServePriority(PriorityHigh, func() {}); err == nil {
+		t.Fatalf("TestFnServePriorityNotConfigured err, get: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("TestFnServePriorityNotConfigured want panic without normal lane")
+		}
+	}()
+	NewPriorityFnWorker(1, LaneConfig{Priority: PriorityHigh, Size: 1})
+}
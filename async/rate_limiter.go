@@ -0,0 +1,220 @@
+package lib
+
+import (
+	"context"
+	"github.com/happyxcj/golib/internal/timerpool"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 清理空闲令牌桶的最小间隔
+const limiterSweepInterval = time.Minute
+
+// 令牌桶限流器：每秒生成rate个令牌，最多存放burst个令牌
+type TokenBucket struct {
+	mu sync.Mutex
+	// 每秒生成的令牌个数
+	rate float64
+	// 令牌桶容量
+	burst float64
+	// 当前令牌个数，为负数时表示已被预订的令牌个数
+	tokens float64
+	// 上次补充令牌的时间
+	last time.Time
+}
+
+// NewTokenBucket 返回每秒生成rate个令牌、容量为burst的令牌桶，初始时令牌桶是满的
+// rate必须大于0，burst<=0时为1
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		panic("rate of token bucket must be positive")
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// advance 按照流逝的时间补充令牌，调用方需持有b.mu
+func (b *TokenBucket) advance(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// Allow 不等待地获取一个令牌，返回是否获取成功
+func (b *TokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Delay 返回获取下一个令牌需要等待的时长，当前有令牌时为0，例如：用于设置retry-after
+func (b *TokenBucket) Delay() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// reserve 预订一个令牌，返回获取到该令牌需要等待的时长
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait 等待获取一个令牌，ctx先结束时归还预订的令牌并返回ctx.Err()
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	d := b.reserve()
+	if d <= 0 {
+		return nil
+	}
+	t := timerpool.Acquire(d)
+	defer timerpool.Release(t)
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// isFull 返回令牌桶是否是满的，满的令牌桶与新建的令牌桶等价
+func (b *TokenBucket) isFull(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	return b.tokens >= b.burst
+}
+
+// 按照key区分的令牌桶限流器，每个key使用独立的令牌桶
+// 令牌桶在第一次使用时创建，补满后的令牌桶会被定期清理
+type KeyedLimiter struct {
+	rate  float64
+	burst int
+	mu    sync.Mutex
+	// 每个key的令牌桶
+	buckets map[uint64]*TokenBucket
+	// 上次清理空闲令牌桶的时间
+	lastSweep time.Time
+}
+
+// NewKeyedLimiter 返回每个key每秒生成rate个令牌、容量为burst的限流器
+func NewKeyedLimiter(rate float64, burst int) *KeyedLimiter {
+	if rate <= 0 {
+		panic("rate of keyed limiter must be positive")
+	}
+	return &KeyedLimiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[uint64]*TokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get 返回指定key的令牌桶，不存在时创建
+func (l *KeyedLimiter) Get(key uint64) *TokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		return b
+	}
+	l.sweep()
+	b := NewTokenBucket(l.rate, l.burst)
+	l.buckets[key] = b
+	return b
+}
+
+// Len 返回当前令牌桶个数
+func (l *KeyedLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep 距上次清理超过limiterSweepInterval时，删除已经补满的令牌桶，调用方需持有l.mu
+func (l *KeyedLimiter) sweep() {
+	now := time.Now()
+	if now.Sub(l.lastSweep) < limiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.isFull(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// WithRateLimit 限制所有方法的执行速率为每秒rate个，允许突发burst个
+// 消费协程在执行方法前等待令牌，等待时长计入执行时长；
+// 放弃剩余方法(StopNow或者Stop超过期限)后不再等待令牌，放弃执行需要等待的方法并计入丢失个数，
+// GracefulStop仍然等待令牌执行完队列中的方法
+// 注意：需要在提交方法前设置
+func (w *FnWorker) WithRateLimit(rate float64, burst int) *FnWorker {
+	w.limiter = NewTokenBucket(rate, burst)
+	return w
+}
+
+// WithKeyRateLimit 限制通过ServeKey、ServeKeyUint64提交的每个key的方法执行速率为每秒rate个，允许突发burst个，
+// 可与WithRateLimit同时使用，此时方法需要先后获取全局和key的令牌
+// 注意：需要在提交方法前设置
+func (w *FnWorker) WithKeyRateLimit(rate float64, burst int) *FnWorker {
+	w.keyLimiter = NewKeyedLimiter(rate, burst)
+	return w
+}
+
+// limit 设置了全局限流器时，返回执行前等待令牌的方法，否则返回fn
+func (w *FnWorker) limit(fn func()) func() {
+	if w.limiter == nil {
+		return fn
+	}
+	return func() {
+		w.waitToken(w.limiter, fn)
+	}
+}
+
+// limitKey 设置了key限流器时，返回执行前等待key的令牌的方法，否则返回fn
+func (w *FnWorker) limitKey(key uint64, fn func()) func() {
+	if w.keyLimiter == nil {
+		return fn
+	}
+	return func() {
+		// 执行时获取令牌桶，避免提交后令牌桶被清理导致同一个key存在两个令牌桶
+		w.waitToken(w.keyLimiter.Get(key), fn)
+	}
+}
+
+// waitToken 等待令牌桶b的令牌后执行fn，等待期间放弃剩余方法时不执行fn并计入丢失个数
+func (w *FnWorker) waitToken(b *TokenBucket, fn func()) {
+	if err := b.Wait(w.abortCtx); err != nil {
+		atomic.AddUint64(&w.lostCount, 1)
+		return
+	}
+	fn()
+}
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(100, 2)
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("TestTokenBucket burst err, index: %v", i)
+		}
+	}
+	if b.Allow() {
+		t.Fatalf("TestTokenBucket err, want: %v, get: %v", false, true)
+	}
+	if d := b.Delay(); d <= 0 || d > time.Millisecond*10 {
+		t.Fatalf("TestTokenBucket Delay err, get: %v", d)
+	}
+	// 每10ms生成一个令牌
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("TestTokenBucket Wait err: %v", err)
+		}
+	}
+	if dur := time.Since(start); dur < time.Millisecond*25 || dur > time.Millisecond*200 {
+		t.Fatalf("TestTokenBucket Wait dur err, get: %v", dur)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	b2 := NewTokenBucket(1, 1)
+	b2.Allow()
+	if err := b2.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("TestTokenBucket Wait err, want: %v, get: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	l := NewKeyedLimiter(1, 1)
+	if !l.Get(1).Allow() || !l.Get(2).Allow() {
+		t.Fatalf("TestKeyedLimiter err, keys should not share tokens")
+	}
+	if l.Get(1).Allow() {
+		t.Fatalf("TestKeyedLimiter err, want: %v, get: %v", false, true)
+	}
+	if l.Get(1) != l.Get(1) || l.Len() != 2 {
+		t.Fatalf("TestKeyedLimiter bucket err, len: %v", l.Len())
+	}
+}
+
+func TestFnWorkerRateLimit(t *testing.T) {
+	fw := NewFnWorker(4, 10).WithRateLimit(100, 1)
+	var wg sync.WaitGroup
+	wg.Add(5)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		fw.Serve(wg.Done)
+	}
+	wg.Wait()
+	if dur := time.Since(start); dur < time.Millisecond*35 || dur > time.Millisecond*300 {
+		t.Fatalf("TestFnWorkerRateLimit dur err, get: %v", dur)
+	}
+	fw.GracefulStop()
+
+	// 不同key的令牌互不影响
+	fw = NewFnWorker(4, 10).WithKeyRateLimit(10, 1)
+	wg.Add(4)
+	start = time.Now()
+	for key := uint64(0); key < 4; key++ {
+		fw.ServeKeyUint64(key, wg.Done)
+	}
+	wg.Wait()
+	if dur := time.Since(start); dur > time.Millisecond*50 {
+		t.Fatalf("TestFnWorkerRateLimit key dur err, get: %v", dur)
+	}
+	wg.Add(2)
+	start = time.Now()
+	fw.ServeKey("user", wg.Done)
+	fw.ServeKey("user", wg.Done)
+	wg.Wait()
+	if dur := time.Since(start); dur < time.Millisecond*80 {
+		t.Fatalf("TestFnWorkerRateLimit same key dur err, get: %v", dur)
+	}
+	fw.GracefulStop()
+}
+
+func TestFnWorkerRateLimitGracefulStop(t *testing.T) {
+	fw := NewFnWorker(1, 10).WithRateLimit(20, 1)
+	var count int32
+	for i := 0; i < 5; i++ {
+		fw.Serve(func() { atomic.AddInt32(&count, 1) })
+	}
+	// 优雅停止时仍然等待令牌执行完队列中的方法
+	if _, lostCount := fw.GracefulStop(); lostCount != 0 {
+		t.Fatalf("TestFnWorkerRateLimitGracefulStop lost err, want: %v, get: %v", 0, lostCount)
+	}
+	if n := atomic.LoadInt32(&count); n != 5 {
+		t.Fatalf("TestFnWorkerRateLimitGracefulStop count err, want: %v, get: %v", 5, n)
+	}
+	if n := fw.Stats().Lost; n != 0 {
+		t.Fatalf("TestFnWorkerRateLimitGracefulStop stats lost err, want: %v, get: %v", 0, n)
+	}
+}
+
+func TestFnWorkerRateLimitStop(t *testing.T) {
+	// 1个执行完成，1个在等待令牌，1个在队列中
+	fw := NewFnWorker(1, 10).WithRateLimit(1, 1)
+	var count int32
+	for i := 0; i < 3; i++ {
+		fw.Serve(func() { atomic.AddInt32(&count, 1) })
+	}
+	time.Sleep(time.Millisecond * 20)
+	if fns := fw.StopNow(); len(fns) != 1 {
+		t.Fatalf("TestFnWorkerRateLimitStop dropped count err, want: %v, get: %v", 1, len(fns))
+	}
+	time.Sleep(time.Millisecond * 20)
+	if n := fw.Stats().Lost; n != 1 {
+		t.Fatalf("TestFnWorkerRateLimitStop lost err, want: %v, get: %v", 1, n)
+	}
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Fatalf("TestFnWorkerRateLimitStop count err, want: %v, get: %v", 1, n)
+	}
+
+	// Stop的ctx结束时不再等待令牌
+	fw = NewFnWorker(1, 10).WithRateLimit(1, 1)
+	for i := 0; i < 3; i++ {
+		fw.Serve(func() {})
+	}
+	time.Sleep(time.Millisecond * 20)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start := time.Now()
+	pendingCount, lostCount, err := fw.Stop(ctx)
+	if pendingCount != 1 || lostCount < 1 || err != context.DeadlineExceeded {
+		t.Fatalf("TestFnWorkerRateLimitStop err, want: [%v_>=%v_%v], get: [%v_%v_%v]", 1, 1, context.DeadlineExceeded, pendingCount, lostCount, err)
+	}
+	if dur := time.Since(start); dur > time.Millisecond*300 {
+		t.Fatalf("TestFnWorkerRateLimitStop dur err, get: %v", dur)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if n := fw.Stats().Lost; n != 1 {
+		t.Fatalf("TestFnWorkerRateLimitStop lost err, want: %v, get: %v", 1, n)
+	}
+}
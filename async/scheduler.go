@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"container/heap"
+	"errors"
+	"github.com/happyxcj/golib/internal/timerpool"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 定时任务，可通过Cancel取消
+type ScheduledTask struct {
+	s *Scheduler
+	// 下次执行的时间
+	at time.Time
+	// 执行间隔，为0时只执行一次
+	interval time.Duration
+	fn       func()
+	// 在任务堆中的索引，不在堆中时为-1
+	index int
+}
+
+// Cancel 取消任务，返回是否取消成功，任务已经执行(只执行一次的任务)或者已经被取消时返回false
+// 注意：不影响已经提交到异步工作者的执行
+func (t *ScheduledTask) Cancel() bool {
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.s.tasks, t.index)
+	return true
+}
+
+// 按照执行时间排序的任务最小堆，实现heap.Interface
+type taskHeap []*ScheduledTask
+
+func (h taskHeap) Len() int {
+	return len(h)
+}
+
+func (h taskHeap) Less(i, j int) bool {
+	return h[i].at.Before(h[j].at)
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	t := x.(*ScheduledTask)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
+
+// 异步工作者队列已满时，一次性任务重新提交的间隔
+const scheduleRetryDelay = 10 * time.Millisecond
+
+// 定时任务调度器：由单个协程按照执行时间维护任务堆，到期的任务不等待地提交到异步工作者执行，
+// 避免为每个延迟任务创建一个协程并time.Sleep；
+// 异步工作者队列已满时，一次性任务在scheduleRetryDelay后重新提交，周期任务跳过该次执行并计数
+type Scheduler struct {
+	// 被异步工作者拒绝而放弃的执行次数，通过atomic访问，放在结构体开头以保证在32位平台上按8字节对齐
+	rejectedCount uint64
+	// 执行到期任务的异步工作者
+	worker *FnWorker
+	// 保护任务堆和stopped
+	mu    sync.Mutex
+	tasks taskHeap
+	// 是否已经停止，停止后不再添加任务
+	stopped bool
+	// 最早执行的任务变化时通知调度协程重新计算等待时长
+	wakeCh chan struct{}
+	// 开始停止时关闭
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// 调度协程退出时关闭
+	doneCh chan struct{}
+}
+
+// NewScheduler 返回把到期任务提交到异步工作者w执行的调度器
+func NewScheduler(w *FnWorker) *Scheduler {
+	if w == nil {
+		panic("worker of scheduler is nil")
+	}
+	s := &Scheduler{
+		worker: w,
+		wakeCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// ScheduleAfter 在d时长后执行方法fn，调度器停止时返回ErrWorkerStopped
+func (s *Scheduler) ScheduleAfter(d time.Duration, fn func()) (*ScheduledTask, error) {
+	return s.schedule(time.Now().Add(d), 0, fn)
+}
+
+// ScheduleAt 在时间t执行方法fn，t已经过去时立即执行，调度器停止时返回ErrWorkerStopped
+func (s *Scheduler) ScheduleAt(t time.Time, fn func()) (*ScheduledTask, error) {
+	return s.schedule(t, 0, fn)
+}
+
+// Every 从interval时长后开始，每隔interval时长执行一次方法fn，直至任务被取消或者调度器停止
+// 按照固定频率提交，不等待上一次执行完成；异步工作者繁忙导致错过的执行会被跳过并计入Rejected
+func (s *Scheduler) Every(interval time.Duration, fn func()) (*ScheduledTask, error) {
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	return s.schedule(time.Now().Add(interval), interval, fn)
+}
+
+func (s *Scheduler) schedule(at time.Time, interval time.Duration, fn func()) (*ScheduledTask, error) {
+	if fn == nil {
+		// 防止人为失误导致协程异常退出服务
+		return nil, errors.New("fn is nil")
+	}
+	t := &ScheduledTask{s: s, at: at, interval: interval, fn: fn}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil, ErrWorkerStopped
+	}
+	heap.Push(&s.tasks, t)
+	earliest := t.index == 0
+	s.mu.Unlock()
+	if earliest {
+		select {
+		case s.wakeCh <- struct{}{}:
+		default:
+		}
+	}
+	return t, nil
+}
+
+// Len 返回等待执行的任务个数
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks)
+}
+
+// Rejected 返回被异步工作者拒绝而放弃的执行次数：队列已满时跳过的周期任务执行，以及异步工作者停止后的所有到期执行
+func (s *Scheduler) Rejected() uint64 {
+	return atomic.LoadUint64(&s.rejectedCount)
+}
+
+// Stop 停止调度器并等待调度协程退出，返回被放弃的任务个数，停止后添加任务返回ErrWorkerStopped
+// 注意：不停止异步工作者
+func (s *Scheduler) Stop() int {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.stopped = true
+		s.mu.Unlock()
+		close(s.stopCh)
+	})
+	<-s.doneCh
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks)
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.doneCh)
+	for {
+		due, wait := s.popDue(time.Now())
+		for _, t := range due {
+			// 不等待，避免异步工作者繁忙时阻塞其他任务和Stop
+			err := s.worker.ServeRightAway(t.fn)
+			if err == nil {
+				continue
+			}
+			if t.interval <= 0 && err != ErrWorkerStopped {
+				s.retry(t)
+				continue
+			}
+			atomic.AddUint64(&s.rejectedCount, 1)
+		}
+		if len(due) > 0 {
+			// 提交期间可能有新的任务到期
+			continue
+		}
+		if !s.wait(wait) {
+			return
+		}
+	}
+}
+
+// wait 等待wait时长或者最早任务变化，wait<0时一直等待，调度器停止时返回false
+func (s *Scheduler) wait(wait time.Duration) bool {
+	var timeoutCh <-chan time.Time
+	if wait >= 0 {
+		t := timerpool.Acquire(wait)
+		defer timerpool.Release(t)
+		timeoutCh = t.C
+	}
+	select {
+	case <-timeoutCh:
+		return true
+	case <-s.wakeCh:
+		return true
+	case <-s.stopCh:
+		return false
+	}
+}
+
+// retry 把被异步工作者拒绝的一次性任务t重新放入任务堆，scheduleRetryDelay后再次提交
+func (s *Scheduler) retry(t *ScheduledTask) {
+	s.mu.Lock()
+	t.at = time.Now().Add(scheduleRetryDelay)
+	heap.Push(&s.tasks, t)
+	s.mu.Unlock()
+}
+
+// popDue 取出所有在now之前到期的任务，周期任务重新放入任务堆
+// 返回：到期的任务和距离最早任务到期的时长，没有任务时为-1
+func (s *Scheduler) popDue(now time.Time) ([]*ScheduledTask, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*ScheduledTask
+	for len(s.tasks) > 0 && !s.tasks[0].at.After(now) {
+		t := s.tasks[0]
+		due = append(due, t)
+		if t.interval <= 0 {
+			heap.Pop(&s.tasks)
+			continue
+		}
+		t.at = t.at.Add(t.interval)
+		if !t.at.After(now) {
+			// 跳过错过的执行
+			t.at = now.Add(t.interval)
+		}
+		heap.Fix(&s.tasks, 0)
+	}
+	if len(s.tasks) == 0 {
+		return due, -1
+	}
+	return due, s.tasks[0].at.Sub(now)
+}
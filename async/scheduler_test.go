@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	fw := NewFnWorker(2, 10)
+	s := NewScheduler(fw)
+	resultCh := make(chan int, 10)
+	if _, err := s.ScheduleAfter(time.Millisecond, nil); err == nil {
+		t.Fatalf("TestScheduler schedule nil err, get: %v", err)
+	}
+	s.ScheduleAfter(time.Millisecond*60, func() { resultCh <- 3 })
+	s.ScheduleAt(time.Now().Add(time.Millisecond*20), func() { resultCh <- 1 })
+	s.ScheduleAfter(time.Millisecond*40, func() { resultCh <- 2 })
+	cancelled, _ := s.ScheduleAfter(time.Millisecond*30, func() { resultCh <- -1 })
+	if !cancelled.Cancel() || cancelled.Cancel() {
+		t.Fatalf("TestScheduler cancel err")
+	}
+	start := time.Now()
+	for want := 1; want <= 3; want++ {
+		if v := <-resultCh; v != want {
+			t.Fatalf("TestScheduler order err, want: %v, get: %v", want, v)
+		}
+	}
+	if dur := time.Since(start); dur < time.Millisecond*50 || dur > time.Millisecond*500 {
+		t.Fatalf("TestScheduler dur err, get: %v", dur)
+	}
+	if s.Len() != 0 {
+		t.Fatalf("TestScheduler len err, want: %v, get: %v", 0, s.Len())
+	}
+	if dropped := s.Stop(); dropped != 0 {
+		t.Fatalf("TestScheduler stop err, want: %v, get: %v", 0, dropped)
+	}
+	if _, err := s.ScheduleAfter(time.Millisecond, func() {}); err != ErrWorkerStopped {
+		t.Fatalf("TestScheduler schedule after stop err, want: %v, get: %v", ErrWorkerStopped, err)
+	}
+	fw.GracefulStop()
+}
+
+func TestSchedulerEvery(t *testing.T) {
+	fw := NewFnWorker(1, 10)
+	s := NewScheduler(fw)
+	if _, err := s.Every(0, func() {}); err == nil {
+		t.Fatalf("TestSchedulerEvery zero interval err, get: %v", err)
+	}
+	var count int32
+	task, err := s.Every(time.Millisecond*20, func() {
+		atomic.AddInt32(&count, 1)
+	})
+	if err != nil {
+		t.Fatalf("TestSchedulerEvery err: %v", err)
+	}
+	time.Sleep(time.Millisecond * 110)
+	if !task.Cancel() {
+		t.Fatalf("TestSchedulerEvery cancel err")
+	}
+	// 等待已经提交的执行完成
+	time.Sleep(time.Millisecond * 10)
+	n := atomic.LoadInt32(&count)
+	if n < 3 || n > 6 {
+		t.Fatalf("TestSchedulerEvery count err, get: %v", n)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if m := atomic.LoadInt32(&count); m != n {
+		t.Fatalf("TestSchedulerEvery count after cancel err, want: %v, get: %v", n, m)
+	}
+	// 停止时放弃等待执行的任务
+	s.ScheduleAfter(time.Hour, func() {})
+	if dropped := s.Stop(); dropped != 1 {
+		t.Fatalf("TestSchedulerEvery stop err, want: %v, get: %v", 1, dropped)
+	}
+	fw.GracefulStop()
+}
+
+func TestSchedulerWorkerBusy(t *testing.T) {
+	fw := NewFnWorker(1, 1)
+	s := NewScheduler(fw)
+	endCh := make(chan int)
+	// 1个执行中，1个在队列中，异步工作者已满
+	fw.Serve(func() { <-endCh })
+	fw.Serve(func() {})
+	time.Sleep(time.Millisecond * 10)
+	var count, tickCount int32
+	for i := 0; i < 3; i++ {
+		s.ScheduleAt(time.Now(), func() { atomic.AddInt32(&count, 1) })
+	}
+	task, _ := s.Every(time.Millisecond*10, func() { atomic.AddInt32(&tickCount, 1) })
+	time.Sleep(time.Millisecond * 50)
+	// 周期任务跳过执行，一次性任务等待重新提交
+	if n := s.Rejected(); n == 0 {
+		t.Fatalf("TestSchedulerWorkerBusy rejected err, get: %v", n)
+	}
+	if n := s.Len(); n != 4 {
+		t.Fatalf("TestSchedulerWorkerBusy len err, want: %v, get: %v", 4, n)
+	}
+	close(endCh)
+	time.Sleep(time.Millisecond * 50)
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Fatalf("TestSchedulerWorkerBusy count err, want: %v, get: %v", 3, n)
+	}
+	task.Cancel()
+	// 异步工作者繁忙时不阻塞Stop
+	fw.Serve(func() { time.Sleep(time.Second) })
+	s.ScheduleAt(time.Now(), func() {})
+	stoppedCh := make(chan int, 1)
+	go func() {
+		stoppedCh <- s.Stop()
+	}()
+	select {
+	case dropped := <-stoppedCh:
+		if dropped > 1 {
+			t.Fatalf("TestSchedulerWorkerBusy stop err, want: <=%v, get: %v", 1, dropped)
+		}
+	case <-time.After(time.Millisecond * 500):
+		t.Fatalf("TestSchedulerWorkerBusy stop is blocked")
+	}
+	fw.StopNow()
+}
@@ -0,0 +1,56 @@
+package lib
+
+import "sync/atomic"
+
+// 异步工作者的运行统计快照
+type FnWorkerStats struct {
+	// 每个消费者所有优先级队列中待处理方法个数
+	QueueLens []int
+	// 所有队列中待处理方法总数
+	Pending int
+	// 每个优先级所有消费者队列中待处理方法个数，下标为Priority
+	LanePending [priorityCount]int
+	// 停止时每个优先级队列中丢失(未执行)的方法个数，下标为Priority
+	LaneLost [priorityCount]uint64
+	// 正在执行的方法个数
+	InFlight int64
+	// 执行完成的方法个数，包括执行panic的方法
+	Completed uint64
+	// ServeRightAway等不等待的提交方式因队列满了拒绝的方法个数
+	Rejected uint64
+	// ServeWithTimeout因超时失败的方法个数
+	TimedOut uint64
+	// 放弃剩余方法(StopNow或者Stop超过期限)后因等待限流令牌失败而放弃执行的方法个数
+	Lost uint64
+	// 方法执行panic的次数
+	Panics uint64
+	// 方法执行返回错误的次数，见WrapErrFn
+	Errors uint64
+}
+
+// Stats 返回工作者当前的运行统计快照
+func (w *FnWorker) Stats() FnWorkerStats {
+	stats := FnWorkerStats{
+		QueueLens: make([]int, w.consumerCount),
+		InFlight:  atomic.LoadInt64(&w.inFlightCount),
+		Completed: atomic.LoadUint64(&w.completedCount),
+		Rejected:  atomic.LoadUint64(&w.rejectedCount),
+		TimedOut:  atomic.LoadUint64(&w.timeoutCount),
+		Lost:      atomic.LoadUint64(&w.lostCount),
+		Panics:    atomic.LoadUint64(&w.panicCount),
+		Errors:    atomic.LoadUint64(&w.errCount),
+	}
+	for p := range stats.LaneLost {
+		stats.LaneLost[p] = atomic.LoadUint64(&w.laneLostCounts[p])
+	}
+	for i := range stats.QueueLens {
+		for p, fnCh := range w.consumerChs(i) {
+			if fnCh != nil {
+				stats.QueueLens[i] += len(fnCh)
+				stats.LanePending[p] += len(fnCh)
+			}
+		}
+		stats.Pending += stats.QueueLens[i]
+	}
+	return stats
+}
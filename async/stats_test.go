@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFnWorkerStats(t *testing.T) {
+	var queueDurCount, execDurCount int64
+	fw := NewFnWorker(2, 2).WithPanicHandler(nil).WithQueueDurHandler(func(dur time.Duration) {
+		atomic.AddInt64(&queueDurCount, 1)
+	}).WithExecDurHandler(func(dur time.Duration) {
+		atomic.AddInt64(&execDurCount, 1)
+	})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	fw.Serve(func() { wg.Done() })
+	fw.Serve(func() {
+		defer wg.Done()
+		panic("test panic")
+	})
+	fw.ServeErr(func() error {
+		defer wg.Done()
+		return nil
+	})
+	wg.Wait()
+	time.Sleep(time.Millisecond * 10)
+
+	// 占满所有消费者和队列
+	endCh := make(chan struct{})
+	startWg := sync.WaitGroup{}
+	startWg.Add(2)
+	for i := 0; i < 2; i++ {
+		fw.Serve(func() {
+			startWg.Done()
+			<-endCh
+		})
+	}
+	startWg.Wait()
+	for i := 0; i < 4; i++ {
+		fw.Serve(func() {})
+	}
+	fw.ServeRightAway(func() {})
+	fw.ServeWithTimeout(func() {}, time.Millisecond)
+
+	stats := fw.Stats()
+	if stats.QueueLens[0] != 2 || stats.QueueLens[1] != 2 || stats.Pending != 4 {
+		t.Fatalf("TestFnWorkerStats queue err, get: %+v", stats)
+	}
+	if stats.InFlight != 2 || stats.Completed != 3 || stats.Panics != 1 {
+		t.Fatalf("TestFnWorkerStats exec err, get: %+v", stats)
+	}
+	if stats.Rejected != 1 || stats.TimedOut != 1 {
+		t.Fatalf("TestFnWorkerStats reject err, get: %+v", stats)
+	}
+	close(endCh)
+	fw.GracefulStop()
+	if stats = fw.Stats(); stats.Completed != 9 || stats.InFlight != 0 {
+		t.Fatalf("TestFnWorkerStats completed err, get: %+v", stats)
+	}
+	if queueDurCount != 9 || execDurCount != 9 {
+		t.Fatalf("TestFnWorkerStats dur err, get: [%v_%v]", queueDurCount, execDurCount)
+	}
+}
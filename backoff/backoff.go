@@ -0,0 +1,39 @@
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Fn 返回第retries次重试(从1开始)前需要等待的时长
+type Fn func(retries int) time.Duration
+
+// NewExp 返回指数退避方法，
+// 第n次重试等待时长为：baseDelay * multiplier^(n-1)，最大不超过maxDelay，
+// 并在此基础上随机浮动±jitter比例，避免多个客户端同时重试
+func NewExp(baseDelay time.Duration, multiplier, jitter float64, maxDelay time.Duration) Fn {
+	return func(retries int) time.Duration {
+		if retries <= 0 {
+			return 0
+		}
+		delay, limit := float64(baseDelay), float64(maxDelay)
+		for i := 1; i < retries && delay < limit; i++ {
+			delay *= multiplier
+		}
+		if delay > limit {
+			delay = limit
+		}
+		// 随机浮动，范围[1-jitter, 1+jitter]
+		delay *= 1 + jitter*(rand.Float64()*2-1)
+		if delay < 0 {
+			return 0
+		}
+		return time.Duration(delay)
+	}
+}
+
+// NewDefaultExp 返回默认指数退避方法，
+// 默认：baseDelay=100毫秒，multiplier=1.6，jitter=0.2，maxDelay=maxDelay
+func NewDefaultExp(maxDelay time.Duration) Fn {
+	return NewExp(100*time.Millisecond, 1.6, 0.2, maxDelay)
+}
@@ -0,0 +1,24 @@
+package backoff
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestExp(t *testing.T) {
+	Convey("TestExp", t, func() {
+		fn := NewExp(time.Millisecond*100, 2, 0, time.Millisecond*300)
+		So(fn(0), ShouldEqual, 0)
+		So(fn(1), ShouldEqual, time.Millisecond*100)
+		So(fn(2), ShouldEqual, time.Millisecond*200)
+		So(fn(3), ShouldEqual, time.Millisecond*300)
+		So(fn(10), ShouldEqual, time.Millisecond*300)
+
+		fn = NewExp(time.Millisecond*100, 2, 0.2, time.Second)
+		for i := 0; i < 100; i++ {
+			d := fn(2)
+			So(d, ShouldBeBetweenOrEqual, time.Millisecond*160, time.Millisecond*240)
+		}
+	})
+}
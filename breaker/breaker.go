@@ -0,0 +1,271 @@
+package breaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+type State int
+
+const (
+	// 关闭状态，所有请求正常通过
+	StateClosed State = iota
+	// 打开状态，所有请求直接失败
+	StateOpen
+	// 半开状态，允许少量探测请求通过
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown state: %d", int(s))
+	}
+}
+
+// 熔断器拒绝请求时返回的错误
+type OpenErr struct {
+	// 熔断器名字
+	Name string
+	// 拒绝请求时熔断器的状态
+	State State
+}
+
+func (e *OpenErr) Error() string {
+	return fmt.Sprintf("circuit breaker '%v' is %v", e.Name, e.State)
+}
+
+// 熔断器配置
+type Config struct {
+	// 统计失败请求的滑动窗口时长
+	Window time.Duration
+	// 滑动窗口划分的桶个数
+	Buckets int
+	// 窗口内请求数达到MinRequests后才按照错误比例判断是否熔断
+	MinRequests int64
+	// 窗口内错误比例达到ErrRatio时熔断，为0时不按照错误比例熔断
+	ErrRatio float64
+	// 连续失败次数达到ConsecutiveFailures时熔断，为0时不按照连续失败次数熔断
+	ConsecutiveFailures int64
+	// 熔断打开持续时长，之后进入半开状态
+	OpenTimeout time.Duration
+	// 半开状态允许通过的探测请求数，全部成功后关闭熔断，任意一个失败则重新打开
+	HalfOpenRequests int64
+	// 状态变化时调用的方法，不关注时可为nil
+	// 注意：它在持有熔断器锁时同步调用，方法内不能再调用该熔断器的方法
+	OnStateChange func(name string, from, to State)
+}
+
+// DefaultConfig 返回默认熔断器配置
+// 默认：10秒窗口(10个桶)内至少20个请求且错误比例达到50%，或者连续失败10次时熔断，
+// 熔断5秒后进入半开状态，允许通过5个探测请求
+func DefaultConfig() Config {
+	return Config{
+		Window:              10 * time.Second,
+		Buckets:             10,
+		MinRequests:         20,
+		ErrRatio:            0.5,
+		ConsecutiveFailures: 10,
+		OpenTimeout:         5 * time.Second,
+		HalfOpenRequests:    5,
+	}
+}
+
+// 熔断器
+// 使用方式：请求前调用Allow，返回错误时直接失败；请求后根据结果调用MarkSuccess或者MarkFailure，并传入Allow返回的代数
+type Breaker struct {
+	name string
+	cfg  Config
+
+	mu    sync.Mutex
+	state State
+	// 状态代数，每次状态变化时加1，用于忽略在之前状态放行的请求结果
+	generation uint64
+	window     *window
+	// 连续失败次数
+	consecutiveFailures int64
+	// 打开状态的开始时间
+	openedAt time.Time
+	// 半开状态已放行的探测请求数
+	halfOpenAllowed int64
+	// 半开状态已成功的探测请求数
+	halfOpenSuccesses int64
+}
+
+// New 根据名字name和配置cfg创建熔断器
+func New(name string, cfg Config) *Breaker {
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 1
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &Breaker{
+		name:   name,
+		cfg:    cfg,
+		window: newWindow(cfg.Window, cfg.Buckets),
+	}
+}
+
+// Name 返回熔断器名字
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State 返回熔断器当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkOpenTimeout(time.Now())
+	return b.state
+}
+
+// Allow 返回是否允许请求通过，不允许时返回*OpenErr
+// 允许时返回当前状态代数，请求结束后传给MarkSuccess或者MarkFailure
+func (b *Breaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkOpenTimeout(time.Now())
+	switch b.state {
+	case StateOpen:
+		return 0, &OpenErr{Name: b.name, State: b.state}
+	case StateHalfOpen:
+		if b.halfOpenAllowed >= b.cfg.HalfOpenRequests {
+			return 0, &OpenErr{Name: b.name, State: b.state}
+		}
+		b.halfOpenAllowed++
+	}
+	return b.generation, nil
+}
+
+// MarkSuccess 记录一次成功请求，generation为放行该请求时Allow返回的代数
+// 熔断器状态已经变化时忽略，避免关闭状态放行的请求被当作半开状态的探测请求
+func (b *Breaker) MarkSuccess(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case StateClosed:
+		b.consecutiveFailures = 0
+		b.window.add(time.Now(), false)
+	case StateHalfOpen:
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.cfg.HalfOpenRequests {
+			b.setState(StateClosed, time.Now())
+		}
+	}
+}
+
+// MarkFailure 记录一次失败请求，generation为放行该请求时Allow返回的代数，熔断器状态已经变化时忽略
+func (b *Breaker) MarkFailure(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	now := time.Now()
+	switch b.state {
+	case StateClosed:
+		b.consecutiveFailures++
+		b.window.add(now, true)
+		if b.shouldTrip(now) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.setState(StateOpen, now)
+	}
+}
+
+// shouldTrip 返回关闭状态下是否需要熔断
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+	if b.cfg.ErrRatio <= 0 {
+		return false
+	}
+	total, failures := b.window.sum(now)
+	return total >= b.cfg.MinRequests && float64(failures)/float64(total) >= b.cfg.ErrRatio
+}
+
+// checkOpenTimeout 打开状态持续时长达到OpenTimeout时进入半开状态
+func (b *Breaker) checkOpenTimeout(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+// setState 切换熔断器状态为state，并重置对应的统计信息
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	b.consecutiveFailures = 0
+	b.halfOpenAllowed = 0
+	b.halfOpenSuccesses = 0
+	b.window.reset()
+	if state == StateOpen {
+		b.openedAt = now
+	}
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.name, from, state)
+	}
+}
+
+// 按照key区分的熔断器集合，每个key使用相同配置的独立熔断器
+// 注意：熔断器创建后不会被删除，集合大小随key的个数增长，key的取值范围应当有限(如host，方法名)
+type Group struct {
+	cfg      Config
+	mu       sync.RWMutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup 返回使用配置cfg的熔断器集合
+func NewGroup(cfg Config) *Group {
+	return &Group{
+		cfg:      cfg,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get 返回key对应的熔断器，不存在时创建
+func (g *Group) Get(key string) *Breaker {
+	g.mu.RLock()
+	b, ok := g.breakers[key]
+	g.mu.RUnlock()
+	if ok {
+		return b
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok = g.breakers[key]; ok {
+		return b
+	}
+	b = New(key, g.cfg)
+	g.breakers[key] = b
+	return b
+}
+
+// States 返回所有熔断器的当前状态，key为熔断器名字
+func (g *Group) States() map[string]State {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	states := make(map[string]State, len(g.breakers))
+	for key, b := range g.breakers {
+		states[key] = b.State()
+	}
+	return states
+}
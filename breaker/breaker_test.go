@@ -0,0 +1,128 @@
+package breaker
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	Convey("TestBreakerConsecutiveFailures", t, func() {
+		var changes []State
+		cfg := Config{
+			Window:              time.Second,
+			Buckets:             10,
+			ConsecutiveFailures: 3,
+			OpenTimeout:         time.Millisecond * 50,
+			HalfOpenRequests:    2,
+			OnStateChange: func(name string, from, to State) {
+				changes = append(changes, to)
+			},
+		}
+		b := New("test", cfg)
+		for i := 0; i < 3; i++ {
+			gen, err := b.Allow()
+			So(err, ShouldBeNil)
+			b.MarkFailure(gen)
+		}
+		So(b.State(), ShouldEqual, StateOpen)
+		_, err := b.Allow()
+		So(err, ShouldHaveSameTypeAs, &OpenErr{})
+		So(err.Error(), ShouldEqual, "circuit breaker 'test' is open")
+
+		// 半开状态只允许HalfOpenRequests个探测请求
+		time.Sleep(time.Millisecond * 60)
+		gen1, err := b.Allow()
+		So(err, ShouldBeNil)
+		gen2, err := b.Allow()
+		So(err, ShouldBeNil)
+		_, err = b.Allow()
+		So(err, ShouldNotBeNil)
+		b.MarkSuccess(gen1)
+		b.MarkSuccess(gen2)
+		So(b.State(), ShouldEqual, StateClosed)
+
+		// 半开状态探测失败则重新打开
+		gen, _ := b.Allow()
+		for i := 0; i < 3; i++ {
+			b.MarkFailure(gen)
+		}
+		time.Sleep(time.Millisecond * 60)
+		gen, err = b.Allow()
+		So(err, ShouldBeNil)
+		b.MarkFailure(gen)
+		So(b.State(), ShouldEqual, StateOpen)
+		So(changes, ShouldResemble, []State{StateOpen, StateHalfOpen, StateClosed, StateOpen, StateHalfOpen, StateOpen})
+	})
+}
+
+func TestBreakerErrRatio(t *testing.T) {
+	Convey("TestBreakerErrRatio", t, func() {
+		cfg := Config{
+			Window:      time.Millisecond * 100,
+			Buckets:     10,
+			MinRequests: 10,
+			ErrRatio:    0.5,
+			OpenTimeout: time.Second,
+		}
+		b := New("test", cfg)
+		gen, _ := b.Allow()
+		for i := 0; i < 9; i++ {
+			b.MarkFailure(gen)
+		}
+		// 未达到最少请求数
+		So(b.State(), ShouldEqual, StateClosed)
+
+		// 窗口过期后重新统计
+		time.Sleep(time.Millisecond * 120)
+		for i := 0; i < 5; i++ {
+			b.MarkSuccess(gen)
+		}
+		for i := 0; i < 4; i++ {
+			b.MarkFailure(gen)
+		}
+		So(b.State(), ShouldEqual, StateClosed)
+		b.MarkFailure(gen)
+		So(b.State(), ShouldEqual, StateOpen)
+	})
+}
+
+func TestBreakerGeneration(t *testing.T) {
+	Convey("TestBreakerGeneration", t, func() {
+		cfg := Config{
+			Window:              time.Second,
+			Buckets:             10,
+			ConsecutiveFailures: 1,
+			OpenTimeout:         time.Millisecond * 50,
+			HalfOpenRequests:    1,
+		}
+		b := New("test", cfg)
+		closedGen, err := b.Allow()
+		So(err, ShouldBeNil)
+		tripGen, _ := b.Allow()
+		b.MarkFailure(tripGen)
+		So(b.State(), ShouldEqual, StateOpen)
+
+		// 关闭状态放行的请求在半开状态结束，不能作为探测请求关闭熔断
+		time.Sleep(time.Millisecond * 60)
+		So(b.State(), ShouldEqual, StateHalfOpen)
+		b.MarkSuccess(closedGen)
+		So(b.State(), ShouldEqual, StateHalfOpen)
+		b.MarkFailure(closedGen)
+		So(b.State(), ShouldEqual, StateHalfOpen)
+
+		probeGen, err := b.Allow()
+		So(err, ShouldBeNil)
+		b.MarkSuccess(probeGen)
+		So(b.State(), ShouldEqual, StateClosed)
+	})
+}
+
+func TestGroup(t *testing.T) {
+	Convey("TestGroup", t, func() {
+		g := NewGroup(DefaultConfig())
+		So(g.Get("a"), ShouldEqual, g.Get("a"))
+		So(g.Get("a"), ShouldNotEqual, g.Get("b"))
+		So(g.States(), ShouldResemble, map[string]State{"a": StateClosed, "b": StateClosed})
+	})
+}
@@ -0,0 +1,63 @@
+package breaker
+
+import "time"
+
+// 滑动窗口的单个桶
+type bucket struct {
+	// 桶所属的时间段序号，即时间戳/桶时长
+	epoch    int64
+	total    int64
+	failures int64
+}
+
+// 按时间分桶统计请求数和失败数的滑动窗口，非并发安全
+type window struct {
+	buckets []bucket
+	// 单个桶的时长
+	size int64
+}
+
+func newWindow(dur time.Duration, buckets int) *window {
+	size := int64(dur) / int64(buckets)
+	if size <= 0 {
+		size = 1
+	}
+	return &window{
+		buckets: make([]bucket, buckets),
+		size:    size,
+	}
+}
+
+// add 在now所在的桶中记录一次请求
+func (w *window) add(now time.Time, failed bool) {
+	epoch := now.UnixNano() / w.size
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		// 桶已过期，重新使用
+		*b = bucket{epoch: epoch}
+	}
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+// sum 返回截止到now的窗口内总请求数和失败数
+func (w *window) sum(now time.Time) (total, failures int64) {
+	epoch := now.UnixNano() / w.size
+	n := int64(len(w.buckets))
+	for _, b := range w.buckets {
+		if epoch-b.epoch < n {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+// reset 清空窗口内所有统计信息
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"google.golang.org/grpc/codes"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// 错误码目录，错误码的唯一来源
+type Catalog struct {
+	// 生成代码的包名
+	Package string `json:"package"`
+	// 错误码集合
+	Codes []*CodeItem `json:"codes"`
+}
+
+// 单个错误码
+type CodeItem struct {
+	// 错误码
+	Code uint32 `json:"code"`
+	// 常量名，必须是合法的Go导出标识符
+	Name string `json:"name"`
+	// 默认错误信息
+	Msg string `json:"msg"`
+	// 按照语言区分的错误信息，例如：{"zh": "参数错误"}，可为空
+	LocaleMsgs map[string]string `json:"locale_msgs"`
+	// http状态码，为0时不注册
+	HTTP int `json:"http"`
+	// grpc状态码名字，例如："InvalidArgument"，为空时不注册
+	GRPC string `json:"grpc"`
+}
+
+// grpc状态码名字到状态码的映射，名字与codes.Code.String()相同
+var grpcCodeNames = make(map[string]codes.Code)
+
+func init() {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		grpcCodeNames[c.String()] = c
+	}
+}
+
+// loadCatalog 读取并校验指定路径path的json格式错误码目录
+func loadCatalog(path string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Catalog)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parse catalog '%v' err: %v", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid catalog '%v': %v", path, err)
+	}
+	return c, nil
+}
+
+// validate 校验错误码目录，并按照错误码升序排序
+func (c *Catalog) validate() error {
+	if !token.IsIdentifier(c.Package) {
+		return fmt.Errorf("package '%v' is not a valid identifier", c.Package)
+	}
+	codeSet := make(map[uint32]string, len(c.Codes))
+	nameSet := make(map[string]struct{}, len(c.Codes))
+	for _, item := range c.Codes {
+		if !token.IsIdentifier(item.Name) || !token.IsExported(item.Name) {
+			return fmt.Errorf("name '%v' is not a valid exported identifier", item.Name)
+		}
+		if name, ok := codeSet[item.Code]; ok {
+			return fmt.Errorf("duplicate code %v for '%v' and '%v'", item.Code, name, item.Name)
+		}
+		if _, ok := nameSet[item.Name]; ok {
+			return fmt.Errorf("duplicate name '%v'", item.Name)
+		}
+		codeSet[item.Code] = item.Name
+		nameSet[item.Name] = struct{}{}
+		if item.Msg == "" || strings.ContainsAny(item.Msg, "\r\n") {
+			return fmt.Errorf("msg of '%v' is empty or multi-line", item.Name)
+		}
+		for locale, msg := range item.LocaleMsgs {
+			if !isLocale(locale) {
+				return fmt.Errorf("locale '%v' of '%v' is invalid", locale, item.Name)
+			}
+			if msg == "" || strings.ContainsAny(msg, "\r\n") {
+				return fmt.Errorf("%v msg of '%v' is empty or multi-line", locale, item.Name)
+			}
+		}
+		if item.HTTP != 0 && http.StatusText(item.HTTP) == "" {
+			return fmt.Errorf("http status %v of '%v' is unknown", item.HTTP, item.Name)
+		}
+		if _, ok := grpcCodeNames[item.GRPC]; item.GRPC != "" && !ok {
+			return fmt.Errorf("grpc code '%v' of '%v' is unknown", item.GRPC, item.Name)
+		}
+	}
+	sort.Slice(c.Codes, func(i, j int) bool {
+		return c.Codes[i].Code < c.Codes[j].Code
+	})
+	return nil
+}
+
+// isLocale 返回locale是否为合法的语言标识，例如："zh"、"en-US"
+func isLocale(locale string) bool {
+	if locale == "" {
+		return false
+	}
+	for _, r := range locale {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Locales 返回所有错误码使用的语言，升序排列
+func (c *Catalog) Locales() []string {
+	set := make(map[string]struct{})
+	for _, item := range c.Codes {
+		for locale := range item.LocaleMsgs {
+			set[locale] = struct{}{}
+		}
+	}
+	locales := make([]string, 0, len(set))
+	for locale := range set {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// HasHTTP 返回是否存在需要注册http状态码的错误码
+func (c *Catalog) HasHTTP() bool {
+	for _, item := range c.Codes {
+		if item.HTTP != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGRPC 返回是否存在需要注册grpc状态码的错误码
+func (c *Catalog) HasGRPC() bool {
+	for _, item := range c.Codes {
+		if item.GRPC != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+var goTmpl = template.Must(template.New("go").Parse(`// Code generated by ecodegen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/happyxcj/golib/ecode"
+{{- if .HasGRPC}}
+	"google.golang.org/grpc/codes"
+{{- end}}
+)
+
+const (
+{{- range .Codes}}
+	// {{.Name}} {{.Msg}}
+	{{.Name}} ecode.ECode = {{.Code}}
+{{- end}}
+)
+
+func init() {
+	ecode.RegisterCMs(map[ecode.ECode]string{
+{{- range .Codes}}
+		{{.Name}}: {{printf "%q" .Msg}},
+{{- end}}
+	})
+{{- $codes := .Codes}}
+{{- range $locale := .Locales}}
+	ecode.RegisterLocaleCMs({{printf "%q" $locale}}, map[ecode.ECode]string{
+{{- range $codes}}{{$name := .Name}}{{with index .LocaleMsgs $locale}}
+		{{$name}}: {{printf "%q" .}},
+{{- end}}{{end}}
+	})
+{{- end}}
+{{- if .HasHTTP}}
+	ecode.RegisterHTTPStatuses(map[ecode.ECode]int{
+{{- range .Codes}}{{if .HTTP}}
+		{{.Name}}: {{.HTTP}},
+{{- end}}{{end}}
+	})
+{{- end}}
+{{- if .HasGRPC}}
+	ecode.RegisterGRPCCodes(map[ecode.ECode]codes.Code{
+{{- range .Codes}}{{if .GRPC}}
+		{{.Name}}: codes.{{.GRPC}},
+{{- end}}{{end}}
+	})
+{{- end}}
+}
+`))
+
+// genGo 根据错误码目录c生成Go代码：错误码常量及其注册方法
+func genGo(c *Catalog) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := goTmpl.Execute(&buf, c); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code err: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+// genMarkdown 根据错误码目录c生成Markdown格式的错误码表格
+func genMarkdown(c *Catalog) []byte {
+	var buf bytes.Buffer
+	locales := c.Locales()
+	buf.WriteString("| Code | Name | Message |")
+	for _, locale := range locales {
+		fmt.Fprintf(&buf, " Message (%v) |", locale)
+	}
+	buf.WriteString(" HTTP | gRPC |\n|---|---|---|")
+	buf.WriteString(strings.Repeat("---|", len(locales)))
+	buf.WriteString("---|---|\n")
+	for _, item := range c.Codes {
+		fmt.Fprintf(&buf, "| %v | %v | %v |", item.Code, item.Name, escapeMarkdown(item.Msg))
+		for _, locale := range locales {
+			fmt.Fprintf(&buf, " %v |", escapeMarkdown(item.LocaleMsgs[locale]))
+		}
+		httpStatus := "-"
+		if item.HTTP != 0 {
+			httpStatus = fmt.Sprint(item.HTTP)
+		}
+		grpcCode := "-"
+		if item.GRPC != "" {
+			grpcCode = item.GRPC
+		}
+		fmt.Fprintf(&buf, " %v | %v |\n", httpStatus, grpcCode)
+	}
+	return buf.Bytes()
+}
+
+// escapeMarkdown 转义Markdown表格单元格中的特殊字符
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGen(t *testing.T) {
+	Convey("TestGen", t, func() {
+		dir, err := ioutil.TempDir("", "ecodegen")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		out, md := filepath.Join(dir, "ecode_gen.go"), filepath.Join(dir, "ECODE.md")
+		So(run("testdata/catalog.json", out, md), ShouldBeNil)
+
+		src, _ := ioutil.ReadFile(out)
+		// 生成的代码可以通过类型检查
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, out, src, parser.ParseComments)
+		So(err, ShouldBeNil)
+		conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+		pkg, err := conf.Check("errcode", fset, []*ast.File{f}, nil)
+		So(err, ShouldBeNil)
+		So(pkg.Scope().Lookup("ParamErr"), ShouldNotBeNil)
+		So(string(src), ShouldStartWith, "// Code generated by ecodegen. DO NOT EDIT.")
+		So(string(src), ShouldContainSubstring, "ParamErr ecode.ECode = 4000")
+		So(string(src), ShouldContainSubstring, `ecode.RegisterLocaleCMs("zh", map[ecode.ECode]string{`)
+		So(string(src), ShouldContainSubstring, "ParamErr:  codes.InvalidArgument,")
+		// 按照错误码升序
+		So(strings.Index(string(src), "ServerErr ecode.ECode"), ShouldBeLessThan, strings.Index(string(src), "ParamErr ecode.ECode"))
+
+		table, _ := ioutil.ReadFile(md)
+		So(string(table), ShouldContainSubstring, "| 4000 | ParamErr | param error | 参数错误 | 400 | InvalidArgument |")
+		So(string(table), ShouldContainSubstring, "| 4001 | NameTooLong | param 'name' is too long |  | - | - |")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	Convey("TestValidate", t, func() {
+		newCatalog := func(items ...*CodeItem) *Catalog {
+			return &Catalog{Package: "errcode", Codes: items}
+		}
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a"}).validate(), ShouldBeNil)
+		So((&Catalog{Package: "err-code"}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "a", Msg: "a"}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a"}, &CodeItem{Code: 1, Name: "B", Msg: "b"}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a"}, &CodeItem{Code: 2, Name: "A", Msg: "b"}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: ""}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", HTTP: 999}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", GRPC: "Invalid"}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", LocaleMsgs: map[string]string{"zh": "甲"}}).validate(), ShouldBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", LocaleMsgs: map[string]string{"": "甲"}}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", LocaleMsgs: map[string]string{"z h": "甲"}}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", LocaleMsgs: map[string]string{"zh": ""}}).validate(), ShouldNotBeNil)
+		So(newCatalog(&CodeItem{Code: 1, Name: "A", Msg: "a", LocaleMsgs: map[string]string{"zh": "甲\n乙"}}).validate(), ShouldNotBeNil)
+	})
+}
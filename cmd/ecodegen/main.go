@@ -0,0 +1,44 @@
+// ecodegen 根据json格式的错误码目录生成错误码常量、注册代码和Markdown文档，使目录成为错误码的唯一来源
+//
+// 用法：
+//
+//	//go:generate go run github.com/happyxcj/golib/cmd/ecodegen -in ecode.json -out ecode_gen.go -md ECODE.md
+//
+// 目录格式见testdata/catalog.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	in := flag.String("in", "ecode.json", "path of the json error code catalog")
+	out := flag.String("out", "ecode_gen.go", "path of the generated go file")
+	md := flag.String("md", "", "path of the generated markdown table, skipped if empty")
+	flag.Parse()
+	if err := run(*in, *out, *md); err != nil {
+		fmt.Fprintf(os.Stderr, "ecodegen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, md string) error {
+	c, err := loadCatalog(in)
+	if err != nil {
+		return err
+	}
+	src, err := genGo(c)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(out, src, 0644); err != nil {
+		return err
+	}
+	if md == "" {
+		return nil
+	}
+	return ioutil.WriteFile(md, genMarkdown(c), 0644)
+}
@@ -0,0 +1,60 @@
+package ecode
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 错误码区间[min, max]对应的值
+type codeRange struct {
+	min, max ECode
+	v        uint32
+}
+
+// 错误码到其他协议状态码(例如：grpc状态码，http状态码)的映射表，并发安全
+// 优先匹配单个错误码，其次匹配范围最小的错误码区间
+type codeTable struct {
+	mu     sync.RWMutex
+	codes  map[ECode]uint32
+	ranges []codeRange
+}
+
+func newCodeTable() *codeTable {
+	return &codeTable{codes: make(map[ECode]uint32)}
+}
+
+// set 设置单个错误码eCode对应的值v
+func (t *codeTable) set(eCode ECode, v uint32) {
+	t.mu.Lock()
+	t.codes[eCode] = v
+	t.mu.Unlock()
+}
+
+// setRange 设置错误码区间[min, max]对应的值v，min>max时panic
+func (t *codeTable) setRange(min, max ECode, v uint32) {
+	if min > max {
+		panic(fmt.Sprintf("invalid code range: [%v, %v]", min, max))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ranges = append(t.ranges, codeRange{min: min, max: max, v: v})
+	sort.SliceStable(t.ranges, func(i, j int) bool {
+		return t.ranges[i].max-t.ranges[i].min < t.ranges[j].max-t.ranges[j].min
+	})
+}
+
+// get 返回错误码eCode对应的值，匹配不到时返回false
+func (t *codeTable) get(eCode ECode) (uint32, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if v, ok := t.codes[eCode]; ok {
+		return v, true
+	}
+	for _, r := range t.ranges {
+		if eCode >= r.min && eCode <= r.max {
+			return r.v, true
+		}
+	}
+	return 0, false
+}
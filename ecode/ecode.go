@@ -1,20 +1,39 @@
 package ecode
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
-var globalCMs = make(map[ECode]string)
-
-// 注册全局通用错误码/错误信息集合
-// 一般在进程初始化时调用
+// 注册全局通用错误码/错误信息集合，合并到DefaultRegistry的默认错误信息表
+// 一般在进程初始化时调用，已存在相同错误码且错误信息不同时panic
 func RegisterCMs(cms map[ECode]string) {
-	globalCMs = cms
+	DefaultRegistry.MustRegister(cms)
+}
+
+// 注册语言locale的全局错误码/错误信息集合，合并到DefaultRegistry对应语言的错误信息表
+// 一般在进程初始化时调用，已存在相同错误码且错误信息不同时panic
+func RegisterLocaleCMs(locale string, cms map[ECode]string) {
+	DefaultRegistry.MustRegisterLocale(locale, cms)
 }
 
 type ECode uint32
 
+// 未知错误码，无法从错误中获取错误码时使用，可在进程初始化时修改
+var UnknownCode ECode = 0
+
 // Msg 返回错误码对应的错误信息，匹配不到时返回"-"
 func (e ECode) Msg() string {
-	msg, ok := globalCMs[e]
+	msg, ok := DefaultRegistry.Msg(e)
+	if !ok {
+		return "-"
+	}
+	return msg
+}
+
+// MsgFor 返回错误码在语言locale中对应的错误信息，回退规则见Registry.MsgFor，匹配不到时返回"-"
+func (e ECode) MsgFor(locale string) string {
+	msg, ok := DefaultRegistry.MsgFor(e, locale)
 	if !ok {
 		return "-"
 	}
@@ -42,6 +61,11 @@ func New(eCode ECode) *Err {
 	return NewEM(eCode.Code(), eCode.Msg())
 }
 
+// NewFor 根据给定错误码eCode及其在语言locale中对应的错误信息构建Err
+func NewFor(eCode ECode, locale string) *Err {
+	return NewEM(eCode.Code(), eCode.MsgFor(locale))
+}
+
 // NewEM 根据给定错误码code和错误信息msg构建Err
 func NewEM(code uint32, msg string) *Err {
 	err := &Err{
@@ -72,9 +96,43 @@ func (e *Err) Msg() string {
 	return e.msg
 }
 
+// Cause 返回造成错误的内在原因，没有时返回nil
+func (e *Err) Cause() error {
+	return e.cause
+}
+
+// Unwrap 返回造成错误的内在原因，使得errors.Is和errors.As可以沿着cause链匹配
+func (e *Err) Unwrap() error {
+	return e.cause
+}
+
+// Is 返回e与target是否为相同错误码的*Err，使得errors.Is(err, ecode.New(code))按照错误码匹配
+// target为nil的*Err时返回false
+func (e *Err) Is(target error) bool {
+	t, ok := target.(*Err)
+	return ok && t != nil && t.code == e.code
+}
+
 func (e *Err) Error() string {
 	if e.cause == nil {
 		return fmt.Sprintf("code: %v, msg: %v", e.code, e.msg)
 	}
 	return fmt.Sprintf("code: %v, msg: %v, cause: %v", e.code, e.msg, e.cause)
 }
+
+// As 返回err的错误链中第一个*Err，没有时返回false
+func As(err error) (*Err, bool) {
+	var e *Err
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// CodeOf 返回err的错误链中第一个*Err的错误码，没有时返回UnknownCode
+func CodeOf(err error) ECode {
+	if e, ok := As(err); ok {
+		return ECode(e.code)
+	}
+	return UnknownCode
+}
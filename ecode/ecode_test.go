@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	. "github.com/smartystreets/goconvey/convey"
+	"io"
 	"testing"
 )
 
@@ -55,3 +56,30 @@ func TestErr(t *testing.T) {
 		So(fmt.Sprintf("code: %v, msg: %v, cause: %v", _ECodeServerErr, _cms[_ECodeServerErr], "mysql error"), ShouldEqual, err.Error())
 	})
 }
+
+func TestErrChain(t *testing.T) {
+	Convey("TestErrChain", t, func() {
+		RegisterCMs(_cms)
+		err := New(_ECodeServerErr).WithCause(io.EOF)
+		wrapped := fmt.Errorf("query user: %w", err)
+
+		So(errors.Is(wrapped, io.EOF), ShouldBeTrue)
+		So(errors.Is(wrapped, New(_ECodeServerErr)), ShouldBeTrue)
+		So(errors.Is(wrapped, New(_ECodeParamErr)), ShouldBeFalse)
+		So(errors.Is(wrapped, (*Err)(nil)), ShouldBeFalse)
+		So(errors.Unwrap(err), ShouldEqual, io.EOF)
+		So(err.Cause(), ShouldEqual, io.EOF)
+
+		var e *Err
+		So(errors.As(wrapped, &e), ShouldBeTrue)
+		So(e, ShouldEqual, err)
+		e, ok := As(wrapped)
+		So(ok, ShouldBeTrue)
+		So(e, ShouldEqual, err)
+
+		So(CodeOf(wrapped), ShouldEqual, _ECodeServerErr)
+		So(CodeOf(fmt.Errorf("param: %w", New(_ECodeParamErr).WithCause(err))), ShouldEqual, _ECodeParamErr)
+		So(CodeOf(io.EOF), ShouldEqual, UnknownCode)
+		So(CodeOf(nil), ShouldEqual, UnknownCode)
+	})
+}
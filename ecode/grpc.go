@@ -0,0 +1,75 @@
+package ecode
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+)
+
+// GRPCDomain 是grpc状态详情errdetails.ErrorInfo中标识错误码的Domain，
+// 此时ErrorInfo.Reason为十进制错误码
+const GRPCDomain = "ecode"
+
+// 错误码对应的grpc状态码
+var grpcCodes = newCodeTable()
+
+// RegisterGRPCCodes 注册错误码对应的grpc状态码，优先级高于RegisterGRPCCodeRange注册的区间
+// 一般在进程初始化时调用
+func RegisterGRPCCodes(cs map[ECode]codes.Code) {
+	for eCode, code := range cs {
+		grpcCodes.set(eCode, uint32(code))
+	}
+}
+
+// RegisterGRPCCodeRange 注册错误码区间[min, max]对应的grpc状态码，区间重叠时使用范围更小的区间
+// min>max时panic，一般在进程初始化时调用，例如：RegisterGRPCCodeRange(4000, 4999, codes.InvalidArgument)
+func RegisterGRPCCodeRange(min, max ECode, code codes.Code) {
+	grpcCodes.setRange(min, max, uint32(code))
+}
+
+// GRPCCode 返回错误码对应的grpc状态码，匹配不到时返回codes.Unknown
+func (e ECode) GRPCCode() codes.Code {
+	if code, ok := grpcCodes.get(e); ok {
+		return codes.Code(code)
+	}
+	return codes.Unknown
+}
+
+// GRPCStatus 返回e对应的grpc状态，使得grpc服务端返回e时客户端可以接收到对应的状态码，
+// 错误码以errdetails.ErrorInfo的形式附加到状态详情中
+func (e *Err) GRPCStatus() *status.Status {
+	s := status.New(ECode(e.code).GRPCCode(), e.msg)
+	info := &errdetails.ErrorInfo{
+		Reason: strconv.FormatUint(uint64(e.code), 10),
+		Domain: GRPCDomain,
+	}
+	if ds, err := s.WithDetails(info); err == nil {
+		return ds
+	}
+	return s
+}
+
+// FromError 从grpc客户端(例如：xgrpc.IClient.Invoke)返回的错误err中还原*Err，err为nil时返回nil
+// err不携带错误码时返回false，此时*Err的错误码为UnknownCode，错误信息为grpc状态信息，cause为err
+func FromError(err error) (*Err, bool) {
+	if err == nil {
+		return nil, true
+	}
+	if e, ok := As(err); ok {
+		return e, true
+	}
+	s, _ := status.FromError(err)
+	for _, detail := range s.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != GRPCDomain {
+			continue
+		}
+		code, pErr := strconv.ParseUint(info.Reason, 10, 32)
+		if pErr != nil {
+			continue
+		}
+		return NewEM(uint32(code), s.Message()), true
+	}
+	return NewEM(UnknownCode.Code(), s.Message()).WithCause(err), false
+}
@@ -0,0 +1,47 @@
+package ecode
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestGRPCStatus(t *testing.T) {
+	Convey("TestGRPCStatus", t, func() {
+		RegisterCMs(_cms)
+		RegisterGRPCCodeRange(3000, 4999, codes.Internal)
+		RegisterGRPCCodeRange(4000, 4999, codes.InvalidArgument)
+		RegisterGRPCCodes(map[ECode]codes.Code{4004: codes.NotFound})
+		So(ECode(_ECodeServerErr).GRPCCode(), ShouldEqual, codes.Internal)
+		So(ECode(_ECodeParamErr).GRPCCode(), ShouldEqual, codes.InvalidArgument)
+		So(ECode(4004).GRPCCode(), ShouldEqual, codes.NotFound)
+		So(ECode(1).GRPCCode(), ShouldEqual, codes.Unknown)
+		So(func() { RegisterGRPCCodeRange(4999, 4000, codes.Internal) }, ShouldPanic)
+
+		// 模拟grpc服务端返回Err，客户端接收到的错误
+		s, _ := status.FromError(New(_ECodeParamErr))
+		So(s.Code(), ShouldEqual, codes.InvalidArgument)
+		So(s.Message(), ShouldEqual, _cms[_ECodeParamErr])
+		clientErr := status.FromProto(s.Proto()).Err()
+
+		err, ok := FromError(clientErr)
+		So(ok, ShouldBeTrue)
+		So(err.Code(), ShouldEqual, _ECodeParamErr)
+		So(err.Msg(), ShouldEqual, _cms[_ECodeParamErr])
+
+		err, ok = FromError(status.Error(codes.Unavailable, "unavailable"))
+		So(ok, ShouldBeFalse)
+		So(err.Code(), ShouldEqual, UnknownCode.Code())
+		So(err.Msg(), ShouldEqual, "unavailable")
+
+		err, ok = FromError(errors.New("other"))
+		So(ok, ShouldBeFalse)
+		So(err.Msg(), ShouldEqual, "other")
+
+		err, ok = FromError(nil)
+		So(ok, ShouldBeTrue)
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,94 @@
+package ecode
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 错误码对应的http状态码
+var httpStatuses = newCodeTable()
+
+// RegisterHTTPStatuses 注册错误码对应的http状态码，优先级高于RegisterHTTPStatusRange注册的区间
+// 一般在进程初始化时调用
+func RegisterHTTPStatuses(ss map[ECode]int) {
+	for eCode, s := range ss {
+		httpStatuses.set(eCode, uint32(s))
+	}
+}
+
+// RegisterHTTPStatusRange 注册错误码区间[min, max]对应的http状态码，区间重叠时使用范围更小的区间
+// min>max时panic，一般在进程初始化时调用，例如：RegisterHTTPStatusRange(4000, 4999, http.StatusBadRequest)
+func RegisterHTTPStatusRange(min, max ECode, s int) {
+	httpStatuses.setRange(min, max, uint32(s))
+}
+
+// HTTPStatus 返回错误码对应的http状态码，匹配不到时返回http.StatusInternalServerError
+func (e ECode) HTTPStatus() int {
+	if s, ok := httpStatuses.get(e); ok {
+		return int(s)
+	}
+	return http.StatusInternalServerError
+}
+
+// Err的http json信封格式：{"code":..,"msg":..}
+type envelope struct {
+	Code uint32 `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// MarshalJSON 把e编码为json信封{"code":..,"msg":..}，不包括cause
+func (e *Err) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&envelope{Code: e.code, Msg: e.msg})
+}
+
+// UnmarshalJSON 从json信封{"code":..,"msg":..}解码e
+func (e *Err) UnmarshalJSON(data []byte) error {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	e.code, e.msg = env.Code, env.Msg
+	return nil
+}
+
+// ServeHTTP 把e以json信封的形式写入响应w，http状态码为错误码对应的HTTPStatus
+func (e *Err) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := e.MarshalJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(ECode(e.code).HTTPStatus())
+	w.Write(data)
+}
+
+// WriteHTTP 把错误err以json信封的形式写入响应w
+// err的错误链中没有*Err时，使用错误码UnknownCode及其错误信息，避免暴露内部错误细节
+func WriteHTTP(w http.ResponseWriter, err error) {
+	e, ok := As(err)
+	if !ok {
+		e = New(UnknownCode).WithCause(err)
+	}
+	e.ServeHTTP(w, nil)
+}
+
+// DecodeEnvelope 尝试把data解码为json信封，data是包含code和msg字段的json对象时返回*Err和true，忽略其他字段
+// 注意：成功响应也可能包含这两个字段，调用方需要结合http状态码判断，见xhttp.DecodeECodeErrResp
+func DecodeEnvelope(data []byte) (*Err, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false
+	}
+	if _, ok := fields["code"]; !ok {
+		return nil, false
+	}
+	if _, ok := fields["msg"]; !ok {
+		return nil, false
+	}
+	e := new(Err)
+	if err := e.UnmarshalJSON(data); err != nil {
+		return nil, false
+	}
+	return e, true
+}
@@ -0,0 +1,41 @@
+package ecode
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	Convey("TestHTTP", t, func() {
+		RegisterCMs(_cms)
+		RegisterHTTPStatusRange(4000, 4999, http.StatusBadRequest)
+		So(ECode(_ECodeParamErr).HTTPStatus(), ShouldEqual, http.StatusBadRequest)
+		So(ECode(_ECodeServerErr).HTTPStatus(), ShouldEqual, http.StatusInternalServerError)
+
+		w := httptest.NewRecorder()
+		New(_ECodeParamErr).WithCause(errors.New("name is empty")).ServeHTTP(w, nil)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Body.String(), ShouldEqual, `{"code":4000,"msg":"param error"}`)
+
+		err, ok := DecodeEnvelope(w.Body.Bytes())
+		So(ok, ShouldBeTrue)
+		So(err.Code(), ShouldEqual, _ECodeParamErr)
+		So(err.Msg(), ShouldEqual, _cms[_ECodeParamErr])
+
+		w = httptest.NewRecorder()
+		WriteHTTP(w, errors.New("mysql error"))
+		So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		So(w.Body.String(), ShouldEqual, `{"code":0,"msg":"-"}`)
+
+		err, ok = DecodeEnvelope([]byte(`{"code":4000,"msg":"param error","detail":"name"}`))
+		So(ok, ShouldBeTrue)
+		So(err.Code(), ShouldEqual, _ECodeParamErr)
+		_, ok = DecodeEnvelope([]byte(`bad gateway`))
+		So(ok, ShouldBeFalse)
+		_, ok = DecodeEnvelope([]byte(`{"age":5}`))
+		So(ok, ShouldBeFalse)
+	})
+}
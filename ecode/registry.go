@@ -0,0 +1,118 @@
+package ecode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 错误码/错误信息注册表，并发安全
+// 包括一个默认错误信息表和多个按照语言(例如："zh-CN"，"en")区分的错误信息表
+type Registry struct {
+	mu sync.RWMutex
+	// 默认错误信息表
+	cms map[ECode]string
+	// 按照语言区分的错误信息表
+	localeCMs map[string]map[ECode]string
+}
+
+// NewRegistry 返回空的注册表实例
+func NewRegistry() *Registry {
+	return &Registry{
+		cms:       make(map[ECode]string),
+		localeCMs: make(map[string]map[ECode]string),
+	}
+}
+
+// Register 合并错误码/错误信息集合cms到默认错误信息表，
+// 已存在相同错误码且错误信息不同时返回错误，此时不会注册cms中的任何错误码
+func (r *Registry) Register(cms map[ECode]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return merge(r.cms, cms, "")
+}
+
+// RegisterLocale 合并错误码/错误信息集合cms到语言locale的错误信息表，规则同Register
+func (r *Registry) RegisterLocale(locale string, cms map[ECode]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	locale = normalizeLocale(locale)
+	dst, ok := r.localeCMs[locale]
+	if !ok {
+		dst = make(map[ECode]string, len(cms))
+		r.localeCMs[locale] = dst
+	}
+	return merge(dst, cms, locale)
+}
+
+// MustRegister 同Register，返回错误时panic，一般在进程初始化时调用
+func (r *Registry) MustRegister(cms map[ECode]string) {
+	if err := r.Register(cms); err != nil {
+		panic(err)
+	}
+}
+
+// MustRegisterLocale 同RegisterLocale，返回错误时panic，一般在进程初始化时调用
+func (r *Registry) MustRegisterLocale(locale string, cms map[ECode]string) {
+	if err := r.RegisterLocale(locale, cms); err != nil {
+		panic(err)
+	}
+}
+
+// Msg 返回默认错误信息表中错误码eCode对应的错误信息，不存在时返回false
+func (r *Registry) Msg(eCode ECode) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	msg, ok := r.cms[eCode]
+	return msg, ok
+}
+
+// MsgFor 返回语言locale中错误码eCode对应的错误信息，不存在时依次回退到基础语言(例如："zh-CN"回退到"zh")
+// 和默认错误信息表，都不存在时返回false
+func (r *Registry) MsgFor(eCode ECode, locale string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	locale = normalizeLocale(locale)
+	for locale != "" {
+		if msg, ok := r.localeCMs[locale][eCode]; ok {
+			return msg, true
+		}
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	msg, ok := r.cms[eCode]
+	return msg, ok
+}
+
+// merge 合并src到dst，存在冲突的错误码时返回错误且不修改dst
+func merge(dst, src map[ECode]string, locale string) error {
+	var conflicts []int
+	for eCode, msg := range src {
+		if old, ok := dst[eCode]; ok && old != msg {
+			conflicts = append(conflicts, int(eCode))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Ints(conflicts)
+		if locale == "" {
+			return fmt.Errorf("duplicate error codes: %v", conflicts)
+		}
+		return fmt.Errorf("duplicate error codes for locale '%v': %v", locale, conflicts)
+	}
+	for eCode, msg := range src {
+		dst[eCode] = msg
+	}
+	return nil
+}
+
+// normalizeLocale 统一语言格式，例如："zh_CN"和"ZH-cn"都转换为"zh-cn"
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+// 默认注册表，包级别的注册和查询方法都使用它
+var DefaultRegistry = NewRegistry()
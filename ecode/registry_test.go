@@ -0,0 +1,75 @@
+package ecode
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("TestRegistry", t, func() {
+		r := NewRegistry()
+		So(r.Register(map[ECode]string{1: "a", 2: "b"}), ShouldBeNil)
+		// 合并，重复注册相同信息不报错
+		So(r.Register(map[ECode]string{2: "b", 3: "c"}), ShouldBeNil)
+		msg, ok := r.Msg(3)
+		So(ok, ShouldBeTrue)
+		So(msg, ShouldEqual, "c")
+
+		// 冲突时不注册任何错误码
+		err := r.Register(map[ECode]string{2: "x", 1: "y", 4: "d"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "duplicate error codes: [1 2]")
+		_, ok = r.Msg(4)
+		So(ok, ShouldBeFalse)
+		So(func() { r.MustRegister(map[ECode]string{1: "y"}) }, ShouldPanic)
+
+		// 多语言回退
+		So(r.RegisterLocale("zh", map[ECode]string{1: "甲", 2: "乙"}), ShouldBeNil)
+		So(r.RegisterLocale("zh_CN", map[ECode]string{1: "甲-cn"}), ShouldBeNil)
+		msg, _ = r.MsgFor(1, "zh-CN")
+		So(msg, ShouldEqual, "甲-cn")
+		msg, _ = r.MsgFor(2, "zh-CN")
+		So(msg, ShouldEqual, "乙")
+		msg, _ = r.MsgFor(3, "zh-CN")
+		So(msg, ShouldEqual, "c")
+		msg, _ = r.MsgFor(1, "en")
+		So(msg, ShouldEqual, "a")
+		_, ok = r.MsgFor(5, "zh")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestRegistryConcurrency(t *testing.T) {
+	Convey("TestRegistryConcurrency", t, func() {
+		r := NewRegistry()
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				r.MustRegister(map[ECode]string{ECode(i): "msg"})
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				r.MsgFor(ECode(i), "en")
+			}(i)
+		}
+		wg.Wait()
+		for i := 0; i < 100; i++ {
+			_, ok := r.Msg(ECode(i))
+			So(ok, ShouldBeTrue)
+		}
+	})
+}
+
+func TestLocaleCMs(t *testing.T) {
+	Convey("TestLocaleCMs", t, func() {
+		RegisterCMs(_cms)
+		RegisterLocaleCMs("zh", map[ECode]string{_ECodeParamErr: "参数错误"})
+		So(ECode(_ECodeParamErr).MsgFor("zh-CN"), ShouldEqual, "参数错误")
+		So(ECode(_ECodeServerErr).MsgFor("zh-CN"), ShouldEqual, _cms[_ECodeServerErr])
+		So(ECode(1).MsgFor("zh"), ShouldEqual, "-")
+		So(NewFor(_ECodeParamErr, "zh").Msg(), ShouldEqual, "参数错误")
+	})
+}
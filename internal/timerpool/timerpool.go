@@ -1,4 +1,5 @@
-package lib
+// timerpool 提供async和xhttp共用的定时器对象池
+package timerpool
 
 import (
 	"sync"
@@ -7,8 +8,8 @@ import (
 
 var globalTimerPool sync.Pool
 
-// 从对象池获取指定超时时间的定时器.
-func acquireTimer(timeout time.Duration) *time.Timer {
+// Acquire 从对象池获取指定超时时间的定时器.
+func Acquire(timeout time.Duration) *time.Timer {
 	v := globalTimerPool.Get()
 	if v == nil {
 		return time.NewTimer(timeout)
@@ -18,8 +19,8 @@ func acquireTimer(timeout time.Duration) *time.Timer {
 	return t
 }
 
-// 释放定时器资源到对象池
-func releaseTimer(t *time.Timer) {
+// Release 释放定时器资源到对象池
+func Release(t *time.Timer) {
 	if !t.Stop() {
 		// 定时器如果还未触发则清除.
 		select {
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/breaker"
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestBreakerClient(t *testing.T) {
+	Convey("TestBreakerClient", t, func() {
+		cfg := breaker.Config{
+			Window:              time.Second,
+			Buckets:             10,
+			ConsecutiveFailures: 2,
+			OpenTimeout:         time.Second,
+		}
+		internal := status.Error(codes.Internal, "internal")
+		invalid := status.Error(codes.InvalidArgument, "invalid")
+		inner := &errsClient{errs: []error{invalid, invalid, internal, internal}}
+		breakerCli := xgrpc.NewBreakerClient(inner, cfg)
+		cli := xgrpc.NewServiceClient(breakerCli, "pb.Test")
+
+		// 客户端错误不触发熔断
+		for i := 0; i < 4; i++ {
+			cli.Invoke(context.Background(), "Test", nil, nil)
+		}
+		So(breakerCli.Breakers.Get("/pb.Test/Test").State(), ShouldEqual, breaker.StateOpen)
+		err := cli.Invoke(context.Background(), "Test", nil, nil)
+		So(status.Code(err), ShouldEqual, codes.Unavailable)
+		So(inner.times, ShouldEqual, 4)
+
+		// 不同方法使用不同的熔断器
+		So(cli.Invoke(context.Background(), "TestV2", nil, nil), ShouldBeNil)
+		So(inner.times, ShouldEqual, 5)
+	})
+}
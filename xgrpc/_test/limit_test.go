@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/xgrpc"
+	"github.com/happyxcj/golib/xgrpc/middleware"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// retryDelayOf 返回错误err中errdetails.RetryInfo建议的重试时长
+func retryDelayOf(err error) time.Duration {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	return -1
+}
+
+func TestRateLimiter(t *testing.T) {
+	Convey("TestRateLimiter", t, func() {
+		limiter := middleware.NewRateLimiter(10, 1).
+			WithKeyFn(middleware.LimitByMethodAndCaller("x-caller")).
+			AddMethodRate("/pb.Test/TestV2", 1000, 2)
+		interceptor := xgrpc.Group().Use(limiter.Handler()).UnaryServerInterceptor()
+		echo := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return req, nil
+		}
+		callerCtx := func(caller string) context.Context {
+			return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-caller", caller))
+		}
+
+		_, err := callInterceptor(callerCtx("a"), interceptor, "/pb.Test/Test", "req", echo)
+		So(err, ShouldBeNil)
+		_, err = callInterceptor(callerCtx("a"), interceptor, "/pb.Test/Test", "req", echo)
+		So(status.Code(err), ShouldEqual, codes.ResourceExhausted)
+		delay := retryDelayOf(err)
+		So(delay, ShouldBeGreaterThan, 0)
+		So(delay, ShouldBeLessThanOrEqualTo, time.Millisecond*100)
+
+		// 不同调用方和方法的限额互不影响
+		_, err = callInterceptor(callerCtx("b"), interceptor, "/pb.Test/Test", "req", echo)
+		So(err, ShouldBeNil)
+		for i := 0; i < 2; i++ {
+			_, err = callInterceptor(callerCtx("a"), interceptor, "/pb.Test/TestV2", "req", echo)
+			So(err, ShouldBeNil)
+		}
+		So(limiter.Allowed(), ShouldEqual, 4)
+		So(limiter.Rejected(), ShouldEqual, 1)
+
+		// 补充令牌后通过
+		time.Sleep(delay)
+		_, err = callInterceptor(callerCtx("a"), interceptor, "/pb.Test/Test", "req", echo)
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	Convey("TestConcurrencyLimiter", t, func() {
+		limiter := middleware.NewConcurrencyLimiter(1).WithRetryAfter(time.Millisecond * 500).
+			AddMethodLimit("/pb.Test/TestV2", 2)
+		interceptor := xgrpc.Group().Use(limiter.Handler()).UnaryServerInterceptor()
+		startCh := make(chan struct{}, 3)
+		endCh := make(chan struct{})
+		block := func(ctx context.Context, req interface{}) (interface{}, error) {
+			startCh <- struct{}{}
+			<-endCh
+			return req, nil
+		}
+		errCh := make(chan error, 3)
+		for _, method := range []string{"/pb.Test/Test", "/pb.Test/TestV2", "/pb.Test/TestV2"} {
+			go func(method string) {
+				_, err := callInterceptor(context.Background(), interceptor, method, "req", block)
+				errCh <- err
+			}(method)
+		}
+		for i := 0; i < 3; i++ {
+			<-startCh
+		}
+		So(limiter.InFlights(), ShouldResemble, map[string]int{"/pb.Test/Test": 1, "/pb.Test/TestV2": 2})
+
+		_, err := callInterceptor(context.Background(), interceptor, "/pb.Test/Test", "req", block)
+		So(status.Code(err), ShouldEqual, codes.ResourceExhausted)
+		So(retryDelayOf(err), ShouldEqual, time.Millisecond*500)
+		_, err = callInterceptor(context.Background(), interceptor, "/pb.Test/TestV2", "req", block)
+		So(status.Code(err), ShouldEqual, codes.ResourceExhausted)
+		So(limiter.Rejected(), ShouldEqual, 2)
+
+		close(endCh)
+		for i := 0; i < 3; i++ {
+			So(<-errCh, ShouldBeNil)
+		}
+		So(limiter.InFlight("/pb.Test/Test"), ShouldEqual, 0)
+		So(len(limiter.InFlights()), ShouldEqual, 0)
+
+		// 限制所有请求
+		global := middleware.NewConcurrencyLimiter(0).WithKeyFn(middleware.LimitGlobal)
+		interceptor = xgrpc.Group().Use(global.Handler()).UnaryServerInterceptor()
+		_, err = callInterceptor(context.Background(), interceptor, "/pb.Test/Test", "req", block)
+		So(status.Code(err), ShouldEqual, codes.ResourceExhausted)
+	})
+}
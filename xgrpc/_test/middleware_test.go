@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/xgrpc"
+	"github.com/happyxcj/golib/xgrpc/_test/pb"
+	"github.com/happyxcj/golib/xgrpc/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// 可校验的请求参数
+type validReq struct {
+	pb.TestReq
+}
+
+func (r *validReq) Validate() error {
+	if r.A == "" {
+		return errors.New("a is required")
+	}
+	return nil
+}
+
+// callInterceptor 使用拦截器interceptor调用方法名为method的处理器handler
+func callInterceptor(ctx context.Context, interceptor grpc.UnaryServerInterceptor, method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+}
+
+func TestMiddleware(t *testing.T) {
+	Convey("TestMiddleware", t, func() {
+		var logs []*middleware.AccessLog
+		var panics []interface{}
+		sb := xgrpc.Group().Use(
+			middleware.AccessLogger(time.Millisecond*50, func(l *middleware.AccessLog) {
+				logs = append(logs, l)
+			}),
+			middleware.Recovery(func(c *xgrpc.Context, r interface{}, stack []byte) {
+				panics = append(panics, r)
+			}),
+			middleware.Validate(),
+		)
+		interceptor := sb.UnaryServerInterceptor()
+		ok := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &pb.TestResp{V: "ok"}, nil
+		}
+
+		// 校验请求参数
+		_, err := callInterceptor(context.Background(), interceptor, "/pb.Test/Test", &validReq{}, ok)
+		So(status.Code(err), ShouldEqual, codes.InvalidArgument)
+		resp, err := callInterceptor(context.Background(), interceptor, "/pb.Test/Test", &validReq{TestReq: pb.TestReq{A: "a"}}, ok)
+		So(err, ShouldBeNil)
+		So(resp.(*pb.TestResp).V, ShouldEqual, "ok")
+
+		// 恢复panic
+		_, err = callInterceptor(context.Background(), interceptor, "/pb.Test/Test", &pb.TestReq{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("test panic")
+		})
+		So(status.Code(err), ShouldEqual, codes.Internal)
+		So(panics, ShouldResemble, []interface{}{"test panic"})
+
+		// 慢请求
+		callInterceptor(context.Background(), interceptor, "/pb.Test/TestV2", &pb.TestReq{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			time.Sleep(time.Millisecond * 60)
+			return ok(ctx, req)
+		})
+		So(len(logs), ShouldEqual, 4)
+		So(logs[0].Code, ShouldEqual, codes.InvalidArgument)
+		So(logs[2].Code, ShouldEqual, codes.Internal)
+		So(logs[1].Slow, ShouldBeFalse)
+		So(logs[3].Slow, ShouldBeTrue)
+		So(logs[3].Method, ShouldEqual, "/pb.Test/TestV2")
+	})
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	Convey("TestAuthMiddleware", t, func() {
+		authFn := func(ctx context.Context, token string) (interface{}, error) {
+			if token != "secret" {
+				return nil, errors.New("invalid token")
+			}
+			return "xcj", nil
+		}
+		var principal interface{}
+		handler := func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+			principal, _ = middleware.Principal(c)
+			return req, nil
+		}
+		bearer := xgrpc.Group().Use(middleware.BearerAuth(authFn), handler).UnaryServerInterceptor()
+		apiKey := xgrpc.Group().Use(middleware.APIKeyAuth("X-Api-Key", authFn), handler).UnaryServerInterceptor()
+		echo := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return req, nil
+		}
+		withMD := func(kv ...string) context.Context {
+			return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+		}
+
+		_, err := callInterceptor(context.Background(), bearer, "/pb.Test/Test", "req", echo)
+		So(status.Code(err), ShouldEqual, codes.Unauthenticated)
+		_, err = callInterceptor(withMD("authorization", "Basic secret"), bearer, "/pb.Test/Test", "req", echo)
+		So(status.Code(err), ShouldEqual, codes.Unauthenticated)
+		_, err = callInterceptor(withMD("authorization", "Bearer wrong"), bearer, "/pb.Test/Test", "req", echo)
+		So(status.Code(err), ShouldEqual, codes.Unauthenticated)
+		So(principal, ShouldBeNil)
+		_, err = callInterceptor(withMD("authorization", "bearer secret"), bearer, "/pb.Test/Test", "req", echo)
+		So(err, ShouldBeNil)
+		So(principal, ShouldEqual, "xcj")
+
+		principal = nil
+		_, err = callInterceptor(withMD("x-api-key", "secret"), apiKey, "/pb.Test/Test", "req", echo)
+		So(err, ShouldBeNil)
+		So(principal, ShouldEqual, "xcj")
+	})
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// 按顺序返回指定错误的客户端
+type errsClient struct {
+	errs  []error
+	times int
+}
+
+func (c *errsClient) Invoke(ctx context.Context, method string, req, resp interface{}, opts ...grpc.CallOption) error {
+	c.times++
+	if c.times > len(c.errs) {
+		return nil
+	}
+	return c.errs[c.times-1]
+}
+
+func TestRetryClient(t *testing.T) {
+	Convey("TestRetryClient", t, func() {
+		unavailable := status.Error(codes.Unavailable, "unavailable")
+		backoff := xgrpc.NewDefaultExpBackoff(time.Millisecond * 10)
+
+		inner := &errsClient{errs: []error{unavailable, unavailable}}
+		cli := xgrpc.NewDefaultRetryClient(inner, 2)
+		cli.Policy.WithBackoff(backoff)
+		So(cli.Invoke(context.Background(), "Test", nil, nil), ShouldBeNil)
+		So(inner.times, ShouldEqual, 3)
+
+		// 不需要重试的状态码
+		inner = &errsClient{errs: []error{status.Error(codes.InvalidArgument, "invalid")}}
+		cli.Inner = inner
+		So(status.Code(cli.Invoke(context.Background(), "Test", nil, nil)), ShouldEqual, codes.InvalidArgument)
+		So(inner.times, ShouldEqual, 1)
+
+		// 指定方法的重试策略
+		inner = &errsClient{errs: []error{unavailable, unavailable}}
+		cli.Inner = inner
+		cli.AddMethodPolicy("TestV2", xgrpc.NewRetryPolicy(1, codes.Unavailable).WithBackoff(backoff))
+		So(status.Code(cli.Invoke(context.Background(), "TestV2", nil, nil)), ShouldEqual, codes.Unavailable)
+		So(inner.times, ShouldEqual, 2)
+
+		// 剩余超时时间不足以退避时不再重试
+		inner = &errsClient{errs: []error{unavailable, unavailable}}
+		cli.Inner = inner
+		cli.Policy.WithBackoff(xgrpc.NewDefaultExpBackoff(time.Second))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		So(status.Code(cli.Invoke(ctx, "Test", nil, nil)), ShouldEqual, codes.Unavailable)
+		So(inner.times, ShouldEqual, 1)
+
+		// 重试预算耗尽后不再重试
+		inner = &errsClient{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+		cli.Inner = inner
+		cli.Policy.WithBackoff(backoff)
+		cli.WithBudget(xgrpc.NewRetryBudget(4, 0.1))
+		So(status.Code(cli.Invoke(context.Background(), "Test", nil, nil)), ShouldEqual, codes.Unavailable)
+		So(inner.times, ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/xgrpc"
+	"github.com/happyxcj/golib/xgrpc/_test/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+// 直接实现pb.TestServer，不需要包装Server.ServeGRPC
+type interceptedServer struct {
+	pb.UnimplementedTestServer
+}
+
+func (*interceptedServer) Test(ctx context.Context, req *pb.TestReq) (*pb.TestResp, error) {
+	return &pb.TestResp{V: req.A + " " + ctx.Value(ctxUserKey{}).(string)}, nil
+}
+
+type ctxUserKey struct{}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	Convey("TestUnaryServerInterceptor", t, func() {
+		var methods []string
+		sb := xgrpc.Group().Use(func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+			methods = append(methods, c.Method)
+			// 替换的上下文传递给grpc生成的方法处理器
+			c.Context = context.WithValue(c.Context, ctxUserKey{}, "xcj")
+			return c.Next(req)
+		}).UseAfter(func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+			resp := req.(*pb.TestResp)
+			resp.V += " after"
+			return resp, nil
+		}).Handle("/pb.Test/TestV2", func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		})
+
+		sc, err := net.Listen("tcp", "localhost:8003")
+		if err != nil {
+			t.Fatalf("unable to listen: %+v", err)
+		}
+		server := grpc.NewServer(grpc.UnaryInterceptor(sb.UnaryServerInterceptor()))
+		defer server.GracefulStop()
+		pb.RegisterTestServer(server, &interceptedServer{})
+		go server.Serve(sc)
+
+		cc, err := grpc.Dial("localhost:8003", grpc.WithInsecure())
+		if err != nil {
+			t.Fatalf("failed to Dial: %+v", err)
+		}
+		defer cc.Close()
+		cli := xgrpc.NewServiceClient(xgrpc.NewBaseClient(cc), "pb.Test")
+
+		resp := new(pb.TestResp)
+		So(cli.Invoke(context.Background(), "Test", &pb.TestReq{A: "hello"}, resp), ShouldBeNil)
+		So(resp.V, ShouldEqual, "hello xcj after")
+
+		// 按照方法名注册的处理链路
+		err = cli.Invoke(context.Background(), "TestV2", &pb.TestReqV2{A: "hello"}, new(pb.TestRespV2))
+		So(status.Code(err), ShouldEqual, codes.PermissionDenied)
+		So(methods, ShouldResemble, []string{"/pb.Test/Test", "/pb.Test/TestV2"})
+	})
+}
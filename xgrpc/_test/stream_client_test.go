@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/xgrpc"
+	"github.com/happyxcj/golib/xgrpc/_test/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamClient(t *testing.T) {
+	Convey("TestStreamClient", t, func() {
+		cc, stop := startStreamServer(t, "localhost:8005")
+		defer stop()
+
+		var mu sync.Mutex
+		durs := make(map[string]time.Duration)
+		inner := xgrpc.NewStreamServiceClient(xgrpc.NewBaseClient(cc), "pb.Stream")
+		timeoutCli := xgrpc.NewStreamTimeoutClient(inner, time.Millisecond*100).AddMethodTimeout("Echo", time.Second)
+		var cli xgrpc.IStreamClient = xgrpc.NewStreamDurClient(timeoutCli, func(method string, dur time.Duration) {
+			mu.Lock()
+			durs[method] = dur
+			mu.Unlock()
+		})
+		getDur := func(method string) (time.Duration, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			dur, ok := durs[method]
+			return dur, ok
+		}
+
+		// 每次接收间隔小于空闲超时，总时长大于空闲超时
+		stream, err := cli.NewStream(context.Background(), &watchStreamDesc, "Watch")
+		So(err, ShouldBeNil)
+		So(stream.SendMsg(&pb.TestReq{A: "w", B: 60}), ShouldBeNil)
+		So(stream.CloseSend(), ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			resp := new(pb.TestResp)
+			So(stream.RecvMsg(resp), ShouldBeNil)
+			So(resp.V, ShouldEqual, "w")
+		}
+		_, ok := getDur("Watch")
+		So(ok, ShouldBeFalse)
+		So(stream.RecvMsg(new(pb.TestResp)), ShouldEqual, io.EOF)
+		dur, ok := getDur("Watch")
+		So(ok, ShouldBeTrue)
+		So(dur, ShouldBeGreaterThanOrEqualTo, time.Millisecond*180)
+
+		// 接收间隔超过空闲超时
+		stream, err = cli.NewStream(context.Background(), &watchStreamDesc, "Watch")
+		So(err, ShouldBeNil)
+		So(stream.SendMsg(&pb.TestReq{A: "w", B: 200}), ShouldBeNil)
+		So(stream.CloseSend(), ShouldBeNil)
+		err = stream.RecvMsg(new(pb.TestResp))
+		So(status.Code(err), ShouldEqual, codes.DeadlineExceeded)
+
+		// 指定方法的空闲超时，调用方取消时结束统计
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err = cli.NewStream(ctx, &echoStreamDesc, "Echo")
+		So(err, ShouldBeNil)
+		time.Sleep(time.Millisecond * 150)
+		So(stream.SendMsg(&pb.TestReq{A: "e"}), ShouldBeNil)
+		resp := new(pb.TestResp)
+		So(stream.RecvMsg(resp), ShouldBeNil)
+		So(resp.V, ShouldEqual, "e")
+		cancel()
+		time.Sleep(time.Millisecond * 20)
+		_, ok = getDur("Echo")
+		So(ok, ShouldBeTrue)
+	})
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"github.com/happyxcj/golib/xgrpc/_test/pb"
+	"google.golang.org/grpc"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 双向流式方法Echo：把每个请求的A作为响应的V返回
+var echoStreamDesc = grpc.StreamDesc{
+	StreamName:    "Echo",
+	Handler:       echoHandler,
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// 手写的流式服务描述，避免依赖protoc生成代码
+var streamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.Stream",
+	HandlerType: (*interface{})(nil),
+	Streams:     []grpc.StreamDesc{echoStreamDesc, watchStreamDesc},
+}
+
+func echoHandler(srv interface{}, stream grpc.ServerStream) error {
+	for {
+		req := new(pb.TestReq)
+		if err := stream.RecvMsg(req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(&pb.TestResp{V: req.A}); err != nil {
+			return err
+		}
+	}
+}
+
+// startStreamServer 在地址hostPort启动注册了pb.Stream服务的grpc服务端，返回连接它的客户端连接和关闭方法
+func startStreamServer(t *testing.T, hostPort string, opts ...grpc.ServerOption) (*grpc.ClientConn, func()) {
+	sc, err := net.Listen("tcp", hostPort)
+	if err != nil {
+		t.Fatalf("unable to listen: %+v", err)
+	}
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&streamServiceDesc, struct{}{})
+	go server.Serve(sc)
+	cc, err := grpc.Dial(hostPort, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to Dial: %+v", err)
+	}
+	return cc, func() {
+		cc.Close()
+		server.Stop()
+	}
+}
+
+// 服务端流式方法Watch：收到请求后每隔B毫秒发送一次A，共发送3次
+var watchStreamDesc = grpc.StreamDesc{
+	StreamName:    "Watch",
+	Handler:       watchHandler,
+	ServerStreams: true,
+}
+
+func watchHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(pb.TestReq)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-time.After(time.Duration(req.B) * time.Millisecond):
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+		if err := stream.SendMsg(&pb.TestResp{V: req.A}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/happyxcj/golib/xgrpc"
+	"github.com/happyxcj/golib/xgrpc/_test/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStreamServerInterceptor(t *testing.T) {
+	Convey("TestStreamServerInterceptor", t, func() {
+		var mu sync.Mutex
+		var logs []string
+		sb := xgrpc.Group().UseStream(func(c *xgrpc.StreamContext) error {
+			c.Set("user", "xcj")
+			// 把接收到的消息转为大写，拒绝发送空消息
+			c.OnRecvMsg(func(c *xgrpc.StreamContext, m interface{}) error {
+				req := m.(*pb.TestReq)
+				req.A = strings.ToUpper(req.A)
+				return nil
+			})
+			c.OnSendMsg(func(c *xgrpc.StreamContext, m interface{}) error {
+				if m.(*pb.TestResp).V == "" {
+					return status.Error(codes.InvalidArgument, "empty message")
+				}
+				return nil
+			})
+			err := c.Next()
+			mu.Lock()
+			logs = append(logs, c.Method+" "+c.MustGet("user").(string))
+			mu.Unlock()
+			return err
+		}).HandleStream("/pb.Stream/Echo", func(c *xgrpc.StreamContext) error {
+			if !c.IsClientStream || !c.IsServerStream {
+				return errors.New("unexpected stream type")
+			}
+			return c.Next()
+		})
+		cc, stop := startStreamServer(t, "localhost:8004", grpc.StreamInterceptor(sb.StreamServerInterceptor()))
+		defer stop()
+
+		// 客户端记录收发的消息
+		var sent, received []string
+		cli := xgrpc.NewStreamMsgClient(xgrpc.NewBaseClient(cc)).OnSendMsg(func(method string, m interface{}) error {
+			sent = append(sent, m.(*pb.TestReq).A)
+			return nil
+		}).OnRecvMsg(func(method string, m interface{}) error {
+			received = append(received, m.(*pb.TestResp).V)
+			return nil
+		})
+		stream, err := cli.NewStream(context.Background(), &echoStreamDesc, "/pb.Stream/Echo")
+		So(err, ShouldBeNil)
+		for _, a := range []string{"a", "b"} {
+			So(stream.SendMsg(&pb.TestReq{A: a}), ShouldBeNil)
+			So(stream.RecvMsg(new(pb.TestResp)), ShouldBeNil)
+		}
+		So(stream.CloseSend(), ShouldBeNil)
+		So(stream.RecvMsg(new(pb.TestResp)), ShouldEqual, io.EOF)
+		So(sent, ShouldResemble, []string{"a", "b"})
+		So(received, ShouldResemble, []string{"A", "B"})
+
+		// 服务端发送消息前的方法返回错误
+		stream, err = cli.NewStream(context.Background(), &echoStreamDesc, "/pb.Stream/Echo")
+		So(err, ShouldBeNil)
+		So(stream.SendMsg(&pb.TestReq{A: ""}), ShouldBeNil)
+		err = stream.RecvMsg(new(pb.TestResp))
+		So(status.Code(err), ShouldEqual, codes.InvalidArgument)
+
+		mu.Lock()
+		defer mu.Unlock()
+		So(logs, ShouldResemble, []string{"/pb.Stream/Echo xcj", "/pb.Stream/Echo xcj"})
+	})
+}
@@ -0,0 +1,20 @@
+package xgrpc
+
+import (
+	xbackoff "github.com/happyxcj/golib/backoff"
+	"google.golang.org/grpc/backoff"
+	"time"
+)
+
+// BackoffFn 返回第retries次重试(从1开始)前需要等待的时长
+type BackoffFn = xbackoff.Fn
+
+// NewExpBackoff 根据指定的退避配置cfg返回指数退避方法，见backoff.NewExp
+func NewExpBackoff(cfg backoff.Config) BackoffFn {
+	return xbackoff.NewExp(cfg.BaseDelay, cfg.Multiplier, cfg.Jitter, cfg.MaxDelay)
+}
+
+// NewDefaultExpBackoff 返回默认指数退避方法，见backoff.NewDefaultExp
+func NewDefaultExpBackoff(maxDelay time.Duration) BackoffFn {
+	return xbackoff.NewDefaultExp(maxDelay)
+}
@@ -0,0 +1,64 @@
+package xgrpc
+
+import (
+	"context"
+	"github.com/happyxcj/golib/breaker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 默认视为服务端失败的状态码，客户端错误(如InvalidArgument，NotFound)不会触发熔断
+var defaultFailureCodes = []codes.Code{
+	codes.Unknown,
+	codes.DeadlineExceeded,
+	codes.ResourceExhausted,
+	codes.Internal,
+	codes.Unavailable,
+	codes.DataLoss,
+}
+
+// 熔断客户端，按照方法名区分熔断器
+// 熔断器打开时直接返回codes.Unavailable状态错误，不再请求内部客户端
+// 注意：一般放在ServiceClient内层，使用完整方法名`/serviceName/method`区分熔断器
+type BreakerClient struct {
+	Inner IClient
+	// 熔断器集合
+	Breakers *breaker.Group
+	// 视为服务端失败的状态码
+	FailureCodes map[codes.Code]struct{}
+}
+
+// NewBreakerClient 返回使用熔断器配置cfg的BreakerClient实例
+// 默认：Unknown，DeadlineExceeded，ResourceExhausted，Internal，Unavailable，DataLoss状态码视为失败
+func NewBreakerClient(inner IClient, cfg breaker.Config) *BreakerClient {
+	c := &BreakerClient{
+		Inner:        inner,
+		Breakers:     breaker.NewGroup(cfg),
+		FailureCodes: make(map[codes.Code]struct{}),
+	}
+	return c.AddFailureCodes(defaultFailureCodes...)
+}
+
+// AddFailureCodes 添加视为服务端失败的状态码failureCodes
+func (c *BreakerClient) AddFailureCodes(failureCodes ...codes.Code) *BreakerClient {
+	for _, code := range failureCodes {
+		c.FailureCodes[code] = struct{}{}
+	}
+	return c
+}
+
+func (c *BreakerClient) Invoke(ctx context.Context, method string, req, resp interface{}, opts ...grpc.CallOption) error {
+	b := c.Breakers.Get(method)
+	generation, err := b.Allow()
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	err = c.Inner.Invoke(ctx, method, req, resp, opts...)
+	if _, ok := c.FailureCodes[status.Code(err)]; ok {
+		b.MarkFailure(generation)
+	} else {
+		b.MarkSuccess(generation)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package xgrpc
 import (
 	"context"
 	"fmt"
+	"google.golang.org/grpc"
 )
 
 // abortIndex 标识CtxHandlerFn的最大个数
@@ -23,6 +24,8 @@ type Context struct {
 	index int
 	// isErr 标识处理完后是否遇到了错误
 	isErr bool
+	// unaryHandler 是grpc生成的方法处理器，仅在一元拦截器的处理链路中有效
+	unaryHandler grpc.UnaryHandler
 	// values 是贯穿整个上下文处理链路handlers的键值对信息
 	// 常规用法是：在当前上下文处理器使用Context.Set方法设置键值对，
 	// 然后在后续的上下文处理器中使用Context.Get或者Context.MustGet方法根据key获取设置的值
@@ -30,7 +33,9 @@ type Context struct {
 }
 
 // reset 重置上下文信息为初始状态.
-func (c *Context) reset(method string, handlers ...CtxHandlerFn) {
+func (c *Context) reset(ctx context.Context, method string, handlers ...CtxHandlerFn) {
+	c.Context = ctx
+	c.unaryHandler = nil
 	c.values = nil
 	c.index = -1
 	c.isErr = false
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// 认证通过后调用方身份在Context中存储使用的key
+const PrincipalKey = "_XGrpc_Principal"
+
+// AuthFn 是认证方法，根据凭证token返回调用方身份，凭证无效时返回错误
+type AuthFn func(ctx context.Context, token string) (interface{}, error)
+
+// BearerAuth 返回从请求元数据authorization中读取"Bearer <token>"凭证并认证的处理器，
+// 认证通过后调用方身份通过Context.Set存储，可使用Principal获取
+func BearerAuth(authFn AuthFn) xgrpc.CtxHandlerFn {
+	return auth("authorization", "bearer ", authFn)
+}
+
+// APIKeyAuth 返回从请求元数据header(例如："x-api-key")中读取凭证并认证的处理器，规则同BearerAuth
+func APIKeyAuth(header string, authFn AuthFn) xgrpc.CtxHandlerFn {
+	return auth(strings.ToLower(header), "", authFn)
+}
+
+// auth 返回从请求元数据header中读取前缀为scheme(不区分大小写)的凭证并认证的处理器
+func auth(header, scheme string, authFn AuthFn) xgrpc.CtxHandlerFn {
+	if authFn == nil {
+		panic("auth fn is nil")
+	}
+	return func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(c.Context)
+		values := md.Get(header)
+		if len(values) == 0 {
+			return nil, status.Errorf(codes.Unauthenticated, "missing credentials in '%v'", header)
+		}
+		token := values[0]
+		if len(token) < len(scheme) || !strings.EqualFold(token[:len(scheme)], scheme) {
+			return nil, status.Errorf(codes.Unauthenticated, "bad credentials scheme in '%v'", header)
+		}
+		token = strings.TrimSpace(token[len(scheme):])
+		principal, err := authFn(c.Context, token)
+		if err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		c.Set(PrincipalKey, principal)
+		return c.Next(req)
+	}
+}
+
+// Principal 返回认证处理器存储的调用方身份
+func Principal(c *xgrpc.Context) (interface{}, bool) {
+	return c.Get(PrincipalKey)
+}
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	lib "github.com/happyxcj/golib/async"
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 限流拒绝请求时，响应头部中建议的重试等待秒数使用的key
+const RetryAfterKey = "retry-after"
+
+// LimitKeyFn 根据请求上下文返回限流使用的key，相同key的请求共享限额
+type LimitKeyFn func(c *xgrpc.Context) string
+
+// LimitByMethod 按照方法限流，即key为Context.Method
+func LimitByMethod(c *xgrpc.Context) string {
+	return c.Method
+}
+
+// LimitGlobal 所有请求共享限额
+func LimitGlobal(c *xgrpc.Context) string {
+	return ""
+}
+
+// LimitByMethodAndCaller 返回按照方法和调用方限流的LimitKeyFn，调用方身份为请求元数据header的值，
+// 不存在时所有这类请求共享该方法的限额
+func LimitByMethodAndCaller(header string) LimitKeyFn {
+	return func(c *xgrpc.Context) string {
+		md, _ := metadata.FromIncomingContext(c.Context)
+		var caller string
+		if values := md.Get(header); len(values) > 0 {
+			caller = values[0]
+		}
+		return c.Method + "|" + caller
+	}
+}
+
+// resourceExhausted 返回建议retryAfter时长后重试的codes.ResourceExhausted错误，
+// 建议时长同时写入响应头部RetryAfterKey(向上取整的秒数)和状态详情errdetails.RetryInfo
+func resourceExhausted(c *xgrpc.Context, retryAfter time.Duration, msg string) error {
+	secs := int64(math.Ceil(retryAfter.Seconds()))
+	// 非grpc服务端调用时设置失败，忽略
+	_ = grpc.SetHeader(c.Context, metadata.Pairs(RetryAfterKey, strconv.FormatInt(secs, 10)))
+	st := status.New(codes.ResourceExhausted, msg)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// 令牌桶限流器，每个key使用独立的令牌桶，可按照方法设置不同的速率
+type RateLimiter struct {
+	// 通过和拒绝的请求数，通过atomic访问，放在结构体开头以保证在32位平台上按8字节对齐
+	allowed  uint64
+	rejected uint64
+	keyFn    LimitKeyFn
+	// 默认限流器
+	limiter *lib.KeyedLimiter
+	// 指定方法的限流器
+	methodLimiters map[string]*lib.KeyedLimiter
+}
+
+// NewRateLimiter 返回每个方法每秒最多rate个请求，允许突发burst个的限流器
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		keyFn:          LimitByMethod,
+		limiter:        lib.NewKeyedLimiter(rate, burst),
+		methodLimiters: make(map[string]*lib.KeyedLimiter),
+	}
+}
+
+// WithKeyFn 设置限流使用的key，例如：LimitByMethodAndCaller("x-caller")
+func (l *RateLimiter) WithKeyFn(keyFn LimitKeyFn) *RateLimiter {
+	l.keyFn = keyFn
+	return l
+}
+
+// AddMethodRate 设置grpc完整方法名为method的方法每秒最多rate个请求，允许突发burst个
+func (l *RateLimiter) AddMethodRate(method string, rate float64, burst int) *RateLimiter {
+	l.methodLimiters[method] = lib.NewKeyedLimiter(rate, burst)
+	return l
+}
+
+// Handler 返回限流处理器，没有令牌时返回codes.ResourceExhausted错误，建议的重试时长为获取下一个令牌需要等待的时长
+func (l *RateLimiter) Handler() xgrpc.CtxHandlerFn {
+	return func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+		limiter, ok := l.methodLimiters[c.Method]
+		if !ok {
+			limiter = l.limiter
+		}
+		h := fnv.New64a()
+		h.Write([]byte(l.keyFn(c)))
+		b := limiter.Get(h.Sum64())
+		if !b.Allow() {
+			atomic.AddUint64(&l.rejected, 1)
+			return nil, resourceExhausted(c, b.Delay(), "rate limit exceeded")
+		}
+		atomic.AddUint64(&l.allowed, 1)
+		return c.Next(req)
+	}
+}
+
+// Allowed 返回通过的请求数
+func (l *RateLimiter) Allowed() uint64 {
+	return atomic.LoadUint64(&l.allowed)
+}
+
+// Rejected 返回被拒绝的请求数
+func (l *RateLimiter) Rejected() uint64 {
+	return atomic.LoadUint64(&l.rejected)
+}
+
+// 并发限制器：限制每个key同时处理的请求数，可按照方法设置不同的上限
+type ConcurrencyLimiter struct {
+	// 被拒绝的请求数，通过atomic访问，放在结构体开头以保证在32位平台上按8字节对齐
+	rejected uint64
+	keyFn    LimitKeyFn
+	// 默认并发上限
+	max int64
+	// 指定方法的并发上限
+	methodMax map[string]int64
+	// 拒绝请求时建议的重试时长
+	retryAfter time.Duration
+	// 保护inFlights
+	mu sync.Mutex
+	// 每个key正在处理的请求数，为0的key会被删除
+	inFlights map[string]int64
+}
+
+// NewConcurrencyLimiter 返回每个方法最多同时处理max个请求的并发限制器，
+// 限制所有请求时使用WithKeyFn(LimitGlobal)
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		keyFn:      LimitByMethod,
+		max:        int64(max),
+		methodMax:  make(map[string]int64),
+		retryAfter: time.Second,
+		inFlights:  make(map[string]int64),
+	}
+}
+
+// WithKeyFn 设置并发限制使用的key，例如：LimitGlobal
+func (l *ConcurrencyLimiter) WithKeyFn(keyFn LimitKeyFn) *ConcurrencyLimiter {
+	l.keyFn = keyFn
+	return l
+}
+
+// WithRetryAfter 设置拒绝请求时建议的重试时长，默认为1秒
+func (l *ConcurrencyLimiter) WithRetryAfter(retryAfter time.Duration) *ConcurrencyLimiter {
+	l.retryAfter = retryAfter
+	return l
+}
+
+// AddMethodLimit 设置grpc完整方法名为method的方法最多同时处理max个请求
+func (l *ConcurrencyLimiter) AddMethodLimit(method string, max int) *ConcurrencyLimiter {
+	l.methodMax[method] = int64(max)
+	return l
+}
+
+// Handler 返回并发限制处理器，达到上限时返回codes.ResourceExhausted错误
+func (l *ConcurrencyLimiter) Handler() xgrpc.CtxHandlerFn {
+	return func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+		max, ok := l.methodMax[c.Method]
+		if !ok {
+			max = l.max
+		}
+		key := l.keyFn(c)
+		if !l.acquire(key, max) {
+			atomic.AddUint64(&l.rejected, 1)
+			return nil, resourceExhausted(c, l.retryAfter, "concurrency limit exceeded")
+		}
+		defer l.release(key)
+		return c.Next(req)
+	}
+}
+
+// acquire key正在处理的请求数小于max时加1并返回true
+func (l *ConcurrencyLimiter) acquire(key string, max int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlights[key] >= max {
+		return false
+	}
+	l.inFlights[key]++
+	return true
+}
+
+// release key正在处理的请求数减1
+func (l *ConcurrencyLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlights[key]--; l.inFlights[key] <= 0 {
+		delete(l.inFlights, key)
+	}
+}
+
+// InFlight 返回key正在处理的请求数
+func (l *ConcurrencyLimiter) InFlight(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.inFlights[key])
+}
+
+// InFlights 返回所有正在处理请求的key及其请求数的快照
+func (l *ConcurrencyLimiter) InFlights() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m := make(map[string]int, len(l.inFlights))
+	for key, n := range l.inFlights {
+		m[key] = int(n)
+	}
+	return m
+}
+
+// Rejected 返回被拒绝的请求数
+func (l *ConcurrencyLimiter) Rejected() uint64 {
+	return atomic.LoadUint64(&l.rejected)
+}
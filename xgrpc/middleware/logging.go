@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"time"
+)
+
+// 单个请求的访问日志
+type AccessLog struct {
+	// grpc完整方法名
+	Method string
+	// 调用方地址，未知时为""
+	Peer string
+	// 处理时长
+	Dur time.Duration
+	// 响应状态码
+	Code codes.Code
+	// 处理错误，成功时为nil
+	Err error
+	// 是否为慢请求
+	Slow bool
+}
+
+// 默认的访问日志处理方法：以key=value格式打印
+func printAccessLog(l *AccessLog) {
+	log.Printf("xgrpc: method=%v peer=%v code=%v dur=%v slow=%v err=%v", l.Method, l.Peer, l.Code, l.Dur, l.Slow, l.Err)
+}
+
+// AccessLogger 返回记录访问日志的处理器，处理时长超过slowThreshold的请求标记为慢请求，slowThreshold<=0时不标记
+// logFn为nil时打印所有访问日志
+func AccessLogger(slowThreshold time.Duration, logFn func(l *AccessLog)) xgrpc.CtxHandlerFn {
+	if logFn == nil {
+		logFn = printAccessLog
+	}
+	return func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+		startT := time.Now()
+		resp, err := c.Next(req)
+		l := &AccessLog{
+			Method: c.Method,
+			Dur:    time.Since(startT),
+			Code:   status.Code(err),
+			Err:    err,
+		}
+		l.Slow = slowThreshold > 0 && l.Dur >= slowThreshold
+		if p, ok := peer.FromContext(c.Context); ok && p.Addr != nil {
+			l.Peer = p.Addr.String()
+		}
+		logFn(l)
+		return resp, err
+	}
+}
+
+// SlowLogger 返回只记录慢请求访问日志的处理器，logFn为nil时打印慢请求的访问日志
+func SlowLogger(slowThreshold time.Duration, logFn func(l *AccessLog)) xgrpc.CtxHandlerFn {
+	if logFn == nil {
+		logFn = printAccessLog
+	}
+	return AccessLogger(slowThreshold, func(l *AccessLog) {
+		if l.Slow {
+			logFn(l)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"runtime/debug"
+)
+
+// RecoveryHandlerFn 是处理服务方法panic的方法，参数为recover()的值和panic时的调用栈
+type RecoveryHandlerFn func(c *xgrpc.Context, r interface{}, stack []byte)
+
+// 默认的panic处理方法：打印方法名、panic信息和调用栈
+func logPanic(c *xgrpc.Context, r interface{}, stack []byte) {
+	log.Printf("xgrpc: method '%v' panic: %v\n%s", c.Method, r, stack)
+}
+
+// Recovery 返回恢复后续处理链路panic的处理器，panic交给handler处理后返回codes.Internal错误，
+// handler为nil时打印panic信息和调用栈
+// 注意：应该作为第一个处理器使用，例如：xgrpc.Use(middleware.Recovery(nil))
+func Recovery(handler RecoveryHandlerFn) xgrpc.CtxHandlerFn {
+	if handler == nil {
+		handler = logPanic
+	}
+	return func(c *xgrpc.Context, req interface{}) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				handler(c, r, debug.Stack())
+				resp, err = nil, status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return c.Next(req)
+	}
+}
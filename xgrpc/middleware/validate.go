@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/happyxcj/golib/xgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 可校验的请求参数，例如：protoc-gen-validate生成的代码
+type Validator interface {
+	Validate() error
+}
+
+// Validate 返回校验请求参数的处理器，实现了Validator的请求参数校验失败时返回codes.InvalidArgument错误，
+// Validate()返回的错误本身是grpc状态错误时原样返回
+func Validate() xgrpc.CtxHandlerFn {
+	return func(c *xgrpc.Context, req interface{}) (interface{}, error) {
+		if v, ok := req.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				if _, ok := status.FromError(err); ok {
+					return nil, err
+				}
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		return c.Next(req)
+	}
+}
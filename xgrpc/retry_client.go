@@ -0,0 +1,200 @@
+package xgrpc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+// 重试策略
+type RetryPolicy struct {
+	// 最大重试次数，不包括首次请求
+	MaxRetries int
+	// 退避方法，返回每次重试前需要等待的时长
+	Backoff BackoffFn
+	// 需要重试的状态码
+	Codes map[codes.Code]struct{}
+}
+
+// NewRetryPolicy 返回最多重试maxRetries次，遇到状态码retryCodes时重试的策略
+// 默认：退避方法为NewDefaultExpBackoff(time.Second)
+func NewRetryPolicy(maxRetries int, retryCodes ...codes.Code) *RetryPolicy {
+	p := &RetryPolicy{
+		MaxRetries: maxRetries,
+		Backoff:    NewDefaultExpBackoff(time.Second),
+		Codes:      make(map[codes.Code]struct{}, len(retryCodes)),
+	}
+	for _, code := range retryCodes {
+		p.Codes[code] = struct{}{}
+	}
+	return p
+}
+
+// WithBackoff 设置退避方法backoff
+func (p *RetryPolicy) WithBackoff(backoff BackoffFn) *RetryPolicy {
+	p.Backoff = backoff
+	return p
+}
+
+// shouldRetry 返回错误err是否需要重试
+func (p *RetryPolicy) shouldRetry(err error) bool {
+	_, ok := p.Codes[status.Code(err)]
+	return ok
+}
+
+// 全局重试预算，避免依赖服务故障时重试放大请求量
+// 与grpc的retryThrottling策略相同：每次失败消耗1个令牌，每次成功返还TokenRatio个令牌，
+// 令牌数不超过MaxTokens，只有令牌数大于MaxTokens/2时才允许重试
+type RetryBudget struct {
+	// 最大令牌数
+	MaxTokens float64
+	// 每次成功返还的令牌数
+	TokenRatio float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewRetryBudget 返回最大令牌数为maxTokens，每次成功返还tokenRatio个令牌的重试预算
+func NewRetryBudget(maxTokens, tokenRatio float64) *RetryBudget {
+	return &RetryBudget{
+		MaxTokens:  maxTokens,
+		TokenRatio: tokenRatio,
+		tokens:     maxTokens,
+	}
+}
+
+// onSuccess 记录一次成功请求
+func (b *RetryBudget) onSuccess() {
+	b.mu.Lock()
+	b.tokens += b.TokenRatio
+	if b.tokens > b.MaxTokens {
+		b.tokens = b.MaxTokens
+	}
+	b.mu.Unlock()
+}
+
+// onFailure 记录一次失败请求，返回是否还允许重试
+func (b *RetryBudget) onFailure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens--
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+	return b.tokens > b.MaxTokens/2
+}
+
+// Tokens 返回当前剩余令牌数
+func (b *RetryBudget) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens
+}
+
+// 失败重试客户端
+// 请求返回的状态码在重试策略中时，按照策略退避后重试，剩余的上下文超时时间不足以等待退避时长时不再重试
+type RetryClient struct {
+	Inner IClient
+	// 默认重试策略
+	Policy *RetryPolicy
+	// 指定方法的重试策略
+	MethodPolicies map[string]*RetryPolicy
+	// 全局重试预算，不限制时可为nil
+	Budget *RetryBudget
+}
+
+// NewRetryClient 返回使用默认重试策略policy的RetryClient实例
+func NewRetryClient(inner IClient, policy *RetryPolicy) *RetryClient {
+	return &RetryClient{
+		Inner:          inner,
+		Policy:         policy,
+		MethodPolicies: make(map[string]*RetryPolicy),
+	}
+}
+
+// NewDefaultRetryClient 返回最多重试maxRetries次的RetryClient实例，
+// 遇到Unavailable，ResourceExhausted，Aborted状态码时重试
+func NewDefaultRetryClient(inner IClient, maxRetries int) *RetryClient {
+	policy := NewRetryPolicy(maxRetries, codes.Unavailable, codes.ResourceExhausted, codes.Aborted)
+	return NewRetryClient(inner, policy)
+}
+
+// WithBudget 设置全局重试预算budget
+func (c *RetryClient) WithBudget(budget *RetryBudget) *RetryClient {
+	c.Budget = budget
+	return c
+}
+
+// AddMethodPolicy 添加一个指定方法的重试策略
+func (c *RetryClient) AddMethodPolicy(method string, policy *RetryPolicy) *RetryClient {
+	c.MethodPolicies[method] = policy
+	return c
+}
+
+// AddMethodPolicies 添加多个指定方法的重试策略
+func (c *RetryClient) AddMethodPolicies(methodPolicies map[string]*RetryPolicy) *RetryClient {
+	for method, policy := range methodPolicies {
+		c.AddMethodPolicy(method, policy)
+	}
+	return c
+}
+
+func (c *RetryClient) Invoke(ctx context.Context, method string, req, resp interface{}, opts ...grpc.CallOption) error {
+	var policy *RetryPolicy
+	if v, ok := c.MethodPolicies[method]; ok {
+		policy = v
+	} else {
+		policy = c.Policy
+	}
+	if policy == nil || policy.MaxRetries <= 0 {
+		return c.Inner.Invoke(ctx, method, req, resp, opts...)
+	}
+	for retries := 0; ; retries++ {
+		err := c.Inner.Invoke(ctx, method, req, resp, opts...)
+		if err == nil {
+			if c.Budget != nil {
+				c.Budget.onSuccess()
+			}
+			return nil
+		}
+		if !policy.shouldRetry(err) {
+			return err
+		}
+		if c.Budget != nil && !c.Budget.onFailure() {
+			return err
+		}
+		if retries >= policy.MaxRetries || !c.wait(ctx, policy, retries+1) {
+			return err
+		}
+	}
+}
+
+// wait 等待第retries次重试的退避时长，返回是否可以继续重试
+func (c *RetryClient) wait(ctx context.Context, policy *RetryPolicy, retries int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var d time.Duration
+	if policy.Backoff != nil {
+		d = policy.Backoff(retries)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		// 剩余时间不足以完成退避等待
+		return false
+	}
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
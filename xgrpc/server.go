@@ -2,6 +2,7 @@ package xgrpc
 
 import (
 	"context"
+	"google.golang.org/grpc"
 	"sync"
 )
 
@@ -45,7 +46,7 @@ func (s *Server) ServeGRPC(ctx context.Context, req interface{}) (interface{}, e
 	}
 	c := ctxPool.Get().(*Context)
 	defer ctxPool.Put(c)
-	c.reset(method, s.handlers...)
+	c.reset(ctx, method, s.handlers...)
 	return c.Next(req)
 }
 
@@ -54,15 +55,29 @@ type ServerBuilder struct {
 	heads []CtxHandlerFn
 	// tails 保存尾部处理器，最终使用Build构造Server实例
 	tails []CtxHandlerFn
+	// methods 保存按照grpc完整方法名(例如："/pb.Test/Test")注册的处理器，仅用于UnaryServerInterceptor
+	methods map[string][]CtxHandlerFn
+	// streamHeads 保存流式处理链路的头部处理器，仅用于StreamServerInterceptor
+	streamHeads []StreamHandlerFn
+	// streamMethods 保存按照grpc完整方法名注册的流式处理器，仅用于StreamServerInterceptor
+	streamMethods map[string][]StreamHandlerFn
 }
 
-// Group 返回一个新的ServerBuilder实例，它的heads和tails从现有的Group复制
+// Group 返回一个新的ServerBuilder实例，它的所有处理器从现有的Group复制
 func (b *ServerBuilder) Group() *ServerBuilder {
 	newS := new(ServerBuilder)
 	newS.heads = make([]CtxHandlerFn, len(b.heads))
 	copy(newS.heads, b.heads)
 	newS.tails = make([]CtxHandlerFn, len(b.tails))
 	copy(newS.tails, b.tails)
+	for method, handlers := range b.methods {
+		newS.Handle(method, handlers...)
+	}
+	newS.streamHeads = make([]StreamHandlerFn, len(b.streamHeads))
+	copy(newS.streamHeads, b.streamHeads)
+	for method, handlers := range b.streamMethods {
+		newS.HandleStream(method, handlers...)
+	}
 	return newS
 }
 
@@ -78,8 +93,23 @@ func (b *ServerBuilder) UseAfter(handlers ...CtxHandlerFn) *ServerBuilder {
 	return b
 }
 
+// Handle 添加指定处理器handlers到grpc完整方法名为fullMethod(例如："/pb.Test/Test")的处理链路中，
+// 它们在UnaryServerInterceptor中位于heads之后、grpc生成的方法处理器之前
+func (b *ServerBuilder) Handle(fullMethod string, handlers ...CtxHandlerFn) *ServerBuilder {
+	if b.methods == nil {
+		b.methods = make(map[string][]CtxHandlerFn)
+	}
+	b.methods[fullMethod] = append(b.methods[fullMethod], handlers...)
+	return b
+}
+
 // Build 构造一个Server实例并返回，Server实例的处理器包括：b.heads + handlers +b.tails
 func (b *ServerBuilder) Build(handlers ...CtxHandlerFn) *Server {
+	return NewServer(b.chain(handlers...)...)
+}
+
+// chain 返回处理链路：b.heads + handlers +b.tails
+func (b *ServerBuilder) chain(handlers ...CtxHandlerFn) []CtxHandlerFn {
 	chain := make([]CtxHandlerFn, len(b.heads)+len(handlers)+len(b.tails))
 	copy(chain, b.heads)
 	copy(chain[len(b.heads):], handlers)
@@ -87,7 +117,38 @@ func (b *ServerBuilder) Build(handlers ...CtxHandlerFn) *Server {
 	if len(chain) > abortIndex {
 		panic("too many context handlers")
 	}
-	return NewServer(chain...)
+	return chain
+}
+
+// UnaryServerInterceptor 返回执行处理链路的grpc一元拦截器，使服务方法无需手写调用Server.ServeGRPC的包装，
+// 处理链路为：b.heads + Handle注册的该方法处理器 + grpc生成的方法处理器 + b.tails，
+// Context.Method为grpc.UnaryServerInfo.FullMethod
+// 注意：处理链路在调用时构造，此后对b的修改不影响返回的拦截器
+func (b *ServerBuilder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	defaultChain := b.chain(invokeUnaryHandler)
+	methodChains := make(map[string][]CtxHandlerFn, len(b.methods))
+	for method, handlers := range b.methods {
+		h := make([]CtxHandlerFn, len(handlers)+1)
+		copy(h, handlers)
+		h[len(handlers)] = invokeUnaryHandler
+		methodChains[method] = b.chain(h...)
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chain, ok := methodChains[info.FullMethod]
+		if !ok {
+			chain = defaultChain
+		}
+		c := ctxPool.Get().(*Context)
+		defer ctxPool.Put(c)
+		c.reset(ctx, info.FullMethod, chain...)
+		c.unaryHandler = handler
+		return c.Next(req)
+	}
+}
+
+// invokeUnaryHandler 调用grpc生成的方法处理器，传入的上下文为处理链路中可能被替换的c.Context
+func invokeUnaryHandler(c *Context, req interface{}) (interface{}, error) {
+	return c.unaryHandler(c.Context, req)
 }
 
 // 默认全局ServerBuilder实例
@@ -108,7 +169,32 @@ func UseAfter(handlers ...CtxHandlerFn) *ServerBuilder {
 	return serverBuilder.UseAfter(handlers...)
 }
 
+// UseStream 添加指定流式处理器handlers到serverBuilder的流式处理链路的头部
+func UseStream(handlers ...StreamHandlerFn) *ServerBuilder {
+	return serverBuilder.UseStream(handlers...)
+}
+
+// HandleStream 添加指定流式处理器handlers到serverBuilder中grpc完整方法名为fullMethod的流式处理链路中
+func HandleStream(fullMethod string, handlers ...StreamHandlerFn) *ServerBuilder {
+	return serverBuilder.HandleStream(fullMethod, handlers...)
+}
+
+// Handle 添加指定处理器handlers到serverBuilder中grpc完整方法名为fullMethod的处理链路中
+func Handle(fullMethod string, handlers ...CtxHandlerFn) *ServerBuilder {
+	return serverBuilder.Handle(fullMethod, handlers...)
+}
+
 // Build 构造一个Server实例并返回，Server实例的处理器包括：serverBuilder.heads + handlers +serverBuilder.tails
 func Build(handlers ...CtxHandlerFn) *Server {
 	return serverBuilder.Build(handlers...)
 }
+
+// UnaryServerInterceptor 返回执行serverBuilder处理链路的grpc一元拦截器
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return serverBuilder.UnaryServerInterceptor()
+}
+
+// StreamServerInterceptor 返回执行serverBuilder流式处理链路的grpc流式拦截器
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return serverBuilder.StreamServerInterceptor()
+}
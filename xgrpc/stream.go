@@ -0,0 +1,187 @@
+package xgrpc
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+)
+
+// StreamHandlerFn 是处理流式上下文的方法
+type StreamHandlerFn func(c *StreamContext) error
+
+// StreamMsgFn 是处理流中单条消息m的方法，可观察或者修改m，返回错误时中止本次收发
+type StreamMsgFn func(c *StreamContext, m interface{}) error
+
+// 服务端流式操作链路上下文
+type StreamContext struct {
+	// Context 是链路上下文，它的初始值是grpc服务端流的上下文，替换后grpc生成的方法处理器通过流的Context()获取到新值
+	context.Context
+	// 服务方法标识，即grpc完整方法名
+	Method string
+	// Stream 是grpc服务端流，中间件可以替换为自己包装的流
+	Stream grpc.ServerStream
+	// 是否为客户端流式方法
+	IsClientStream bool
+	// 是否为服务端流式方法
+	IsServerStream bool
+	// 处理链路
+	handlers []StreamHandlerFn
+	// index 是上下文处理链路handlers当前的索引.
+	index int
+	// isErr 标识处理完后是否遇到了错误
+	isErr bool
+	// 发送每条消息前依次执行的方法
+	sendMsgFns []StreamMsgFn
+	// 成功接收每条消息后依次执行的方法
+	recvMsgFns []StreamMsgFn
+	// grpc生成的方法处理器及其服务实现
+	streamHandler grpc.StreamHandler
+	srv           interface{}
+	// values 是贯穿整个上下文处理链路handlers的键值对信息，用法同Context.values
+	values map[string]interface{}
+}
+
+// IsErr 返回是否上下文处理完，遇到了错误返回
+func (c *StreamContext) IsErr() bool {
+	return c.isErr
+}
+
+// Next 执行上下文处理链路handlers中待处理方法，用法同Context.Next
+func (c *StreamContext) Next() error {
+	c.index++
+	for n := len(c.handlers); c.index < n; c.index++ {
+		if err := c.handlers[c.index](c); err != nil {
+			c.isErr = true
+			return err
+		}
+	}
+	return nil
+}
+
+// OnSendMsg 添加发送每条消息前执行的方法fn，fn返回错误时不发送该消息
+// 注意：需要在调用Next前添加，因为收发消息可能在不同的协程中并发进行
+func (c *StreamContext) OnSendMsg(fn StreamMsgFn) {
+	c.sendMsgFns = append(c.sendMsgFns, fn)
+}
+
+// OnRecvMsg 添加成功接收每条消息后执行的方法fn，fn返回错误时本次接收返回该错误
+// 注意：需要在调用Next前添加，因为收发消息可能在不同的协程中并发进行
+func (c *StreamContext) OnRecvMsg(fn StreamMsgFn) {
+	c.recvMsgFns = append(c.recvMsgFns, fn)
+}
+
+// Set 为上下文链路存储一个键值对信息
+func (c *StreamContext) Set(key string, v interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = v
+}
+
+// Get 根据指定的key返回上下文中已存储的value，如果对于的key不存在则返回nil
+func (c *StreamContext) Get(key string) (interface{}, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// MustGet 根据指定的key返回上下文中已存储的value，如果对于的key不存在panic
+func (c *StreamContext) MustGet(key string) interface{} {
+	if v, ok := c.Get(key); ok {
+		return v
+	}
+	panic(fmt.Sprintf("key '%v' does not exist", key))
+}
+
+// 包装的服务端流，使grpc生成的方法处理器使用链路上下文，并在收发消息时执行StreamContext中添加的方法
+type serverStream struct {
+	grpc.ServerStream
+	c *StreamContext
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.c.Context
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	for _, fn := range s.c.sendMsgFns {
+		if err := fn(s.c, m); err != nil {
+			return err
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	for _, fn := range s.c.recvMsgFns {
+		if err := fn(s.c, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invokeStreamHandler 使用包装后的c.Stream调用grpc生成的方法处理器
+func invokeStreamHandler(c *StreamContext) error {
+	return c.streamHandler(c.srv, &serverStream{ServerStream: c.Stream, c: c})
+}
+
+// UseStream 添加指定流式处理器handlers到流式处理链路的头部
+func (b *ServerBuilder) UseStream(handlers ...StreamHandlerFn) *ServerBuilder {
+	b.streamHeads = append(b.streamHeads, handlers...)
+	return b
+}
+
+// HandleStream 添加指定流式处理器handlers到grpc完整方法名为fullMethod的流式处理链路中，
+// 它们位于UseStream添加的处理器之后、grpc生成的方法处理器之前
+func (b *ServerBuilder) HandleStream(fullMethod string, handlers ...StreamHandlerFn) *ServerBuilder {
+	if b.streamMethods == nil {
+		b.streamMethods = make(map[string][]StreamHandlerFn)
+	}
+	b.streamMethods[fullMethod] = append(b.streamMethods[fullMethod], handlers...)
+	return b
+}
+
+// streamChain 返回流式处理链路：b.streamHeads + handlers + grpc生成的方法处理器
+func (b *ServerBuilder) streamChain(handlers ...StreamHandlerFn) []StreamHandlerFn {
+	chain := make([]StreamHandlerFn, len(b.streamHeads)+len(handlers)+1)
+	copy(chain, b.streamHeads)
+	copy(chain[len(b.streamHeads):], handlers)
+	chain[len(chain)-1] = invokeStreamHandler
+	if len(chain) > abortIndex {
+		panic("too many stream context handlers")
+	}
+	return chain
+}
+
+// StreamServerInterceptor 返回执行流式处理链路的grpc流式拦截器，
+// 处理链路为：UseStream添加的处理器 + HandleStream注册的该方法处理器 + grpc生成的方法处理器，
+// StreamContext.Method为grpc.StreamServerInfo.FullMethod
+// 注意：处理链路在调用时构造，此后对b的修改不影响返回的拦截器
+func (b *ServerBuilder) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	defaultChain := b.streamChain()
+	methodChains := make(map[string][]StreamHandlerFn, len(b.streamMethods))
+	for method, handlers := range b.streamMethods {
+		methodChains[method] = b.streamChain(handlers...)
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		chain, ok := methodChains[info.FullMethod]
+		if !ok {
+			chain = defaultChain
+		}
+		c := &StreamContext{
+			Context:        ss.Context(),
+			Method:         info.FullMethod,
+			Stream:         ss,
+			IsClientStream: info.IsClientStream,
+			IsServerStream: info.IsServerStream,
+			handlers:       chain,
+			index:          -1,
+			streamHandler:  handler,
+			srv:            srv,
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,321 @@
+package xgrpc
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 流式客户端接口，与IClient对应，装饰器同样通过Inner组成调用链
+type IStreamClient interface {
+	NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error)
+}
+
+func (c *BaseClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.Conn.NewStream(ctx, desc, method, opts...)
+}
+
+// ClientMsgFn 是处理客户端流中单条消息m的方法，method为grpc完整方法名，可观察或者修改m，返回错误时中止本次收发
+type ClientMsgFn func(method string, m interface{}) error
+
+// 包装的客户端流，在收发消息时执行指定的方法
+type clientStream struct {
+	grpc.ClientStream
+	method     string
+	sendMsgFns []ClientMsgFn
+	recvMsgFns []ClientMsgFn
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	for _, fn := range s.sendMsgFns {
+		if err := fn(s.method, m); err != nil {
+			return err
+		}
+	}
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return err
+	}
+	for _, fn := range s.recvMsgFns {
+		if err := fn(s.method, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 观察或者修改流中每条收发消息的客户端，例如：记录消息日志、校验消息
+type StreamMsgClient struct {
+	Inner IStreamClient
+	// 发送每条消息前依次执行的方法
+	SendMsgFns []ClientMsgFn
+	// 成功接收每条消息后依次执行的方法
+	RecvMsgFns []ClientMsgFn
+}
+
+func NewStreamMsgClient(inner IStreamClient) *StreamMsgClient {
+	return &StreamMsgClient{
+		Inner: inner,
+	}
+}
+
+// OnSendMsg 添加发送每条消息前执行的方法fn，fn返回错误时不发送该消息
+func (c *StreamMsgClient) OnSendMsg(fn ClientMsgFn) *StreamMsgClient {
+	c.SendMsgFns = append(c.SendMsgFns, fn)
+	return c
+}
+
+// OnRecvMsg 添加成功接收每条消息后执行的方法fn，fn返回错误时本次接收返回该错误
+func (c *StreamMsgClient) OnRecvMsg(fn ClientMsgFn) *StreamMsgClient {
+	c.RecvMsgFns = append(c.RecvMsgFns, fn)
+	return c
+}
+
+func (c *StreamMsgClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	cs, err := c.Inner.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &clientStream{
+		ClientStream: cs,
+		method:       method,
+		sendMsgFns:   c.SendMsgFns,
+		recvMsgFns:   c.RecvMsgFns,
+	}, nil
+}
+
+// isStreamFinished 返回RecvMsg返回err后流是否已经结束：出错(包括io.EOF)或者服务端非流式时已接收到唯一的响应
+func isStreamFinished(desc *grpc.StreamDesc, err error) bool {
+	return err != nil || !desc.ServerStreams
+}
+
+// 使用相同服务名字的流式客户端，即最终的method=`/serviceName/method`
+type StreamServiceClient struct {
+	Inner       IStreamClient
+	ServiceName string
+}
+
+func NewStreamServiceClient(inner IStreamClient, serviceName string) *StreamServiceClient {
+	return &StreamServiceClient{
+		Inner:       inner,
+		ServiceName: serviceName,
+	}
+}
+
+func (c *StreamServiceClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	method = fmt.Sprintf("/%v/%v", c.ServiceName, method)
+	return c.Inner.NewStream(ctx, desc, method, opts...)
+}
+
+// 统计流式请求时长客户端，时长为创建流到流结束(接收完所有消息、出错或者ctx结束)
+type StreamDurClient struct {
+	Inner IStreamClient
+	// 处理请求时长的方法
+	HandleDurFn func(method string, dur time.Duration)
+}
+
+func NewStreamDurClient(inner IStreamClient, handleDurFn func(method string, dur time.Duration)) *StreamDurClient {
+	return &StreamDurClient{
+		Inner:       inner,
+		HandleDurFn: handleDurFn,
+	}
+}
+
+func (c *StreamDurClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	startT := time.Now()
+	cs, err := c.Inner.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		c.HandleDurFn(method, time.Since(startT))
+		return nil, err
+	}
+	s := &durStream{
+		ClientStream: cs,
+		desc:         desc,
+		doneCh:       make(chan struct{}),
+		onFinish: func() {
+			c.HandleDurFn(method, time.Since(startT))
+		},
+	}
+	go s.watch(ctx)
+	return s, nil
+}
+
+// 包装的客户端流，流结束时执行一次onFinish
+type durStream struct {
+	grpc.ClientStream
+	desc       *grpc.StreamDesc
+	onFinish   func()
+	finishOnce sync.Once
+	// 流结束时关闭
+	doneCh chan struct{}
+}
+
+// watch 在ctx结束时结束流，用于调用方不再接收消息而是直接取消的情况
+func (s *durStream) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		s.finish()
+	case <-s.doneCh:
+	}
+}
+
+func (s *durStream) finish() {
+	s.finishOnce.Do(func() {
+		close(s.doneCh)
+		s.onFinish()
+	})
+}
+
+func (s *durStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		// io.EOF时流的最终状态由RecvMsg返回
+		s.finish()
+	}
+	return err
+}
+
+func (s *durStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if isStreamFinished(s.desc, err) {
+		s.finish()
+	}
+	return err
+}
+
+// 流式请求空闲超时客户端：两次收发消息之间的间隔超过超时时间时取消流，此时收发消息返回codes.DeadlineExceeded
+type StreamTimeoutClient struct {
+	Inner IStreamClient
+	// 默认空闲超时
+	Timeout time.Duration
+	// 指定方法的空闲超时
+	MethodTimeouts map[string]time.Duration
+}
+
+func NewStreamTimeoutClient(inner IStreamClient, timeout time.Duration) *StreamTimeoutClient {
+	return &StreamTimeoutClient{
+		Inner:          inner,
+		Timeout:        timeout,
+		MethodTimeouts: make(map[string]time.Duration),
+	}
+}
+
+// AddMethodTimeout 添加一个指定方法的空闲超时
+func (c *StreamTimeoutClient) AddMethodTimeout(method string, timeout time.Duration) *StreamTimeoutClient {
+	c.MethodTimeouts[method] = timeout
+	return c
+}
+
+// AddMethodTimeouts 添加多个指定方法的空闲超时，注意：不会覆盖c已有method
+func (c *StreamTimeoutClient) AddMethodTimeouts(methodTimeouts map[string]time.Duration) *StreamTimeoutClient {
+	for method, timeout := range methodTimeouts {
+		c.AddMethodTimeout(method, timeout)
+	}
+	return c
+}
+
+func (c *StreamTimeoutClient) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	var timeout time.Duration
+	if v, ok := c.MethodTimeouts[method]; ok {
+		timeout = v
+	} else {
+		timeout = c.Timeout
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	s := &idleStream{
+		desc:       desc,
+		timeout:    timeout,
+		cancel:     cancel,
+		lastActive: time.Now().UnixNano(),
+	}
+	// 创建流的过程同样受空闲超时限制
+	s.mu.Lock()
+	s.timer = time.AfterFunc(timeout, s.check)
+	s.mu.Unlock()
+	cs, err := c.Inner.NewStream(cancelCtx, desc, method, opts...)
+	if err != nil {
+		s.finish()
+		return nil, s.convertErr(err)
+	}
+	s.ClientStream = cs
+	return s, nil
+}
+
+// 包装的客户端流，两次收发消息之间的间隔超过timeout时取消流
+type idleStream struct {
+	// 上次收发消息的时间，单位：纳秒，通过atomic访问，放在结构体开头以保证在32位平台上按8字节对齐
+	lastActive int64
+	grpc.ClientStream
+	desc    *grpc.StreamDesc
+	timeout time.Duration
+	// 取消流的上下文
+	cancel context.CancelFunc
+	// 保护timer和finished
+	mu    sync.Mutex
+	timer *time.Timer
+	// 流是否已经结束
+	finished bool
+	// 是否因为空闲超时取消了流
+	timedOut int32
+}
+
+// check 空闲时长超过timeout时取消流，否则重新等待剩余时长
+func (s *idleStream) check() {
+	idle := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&s.lastActive))
+	if idle < s.timeout {
+		s.mu.Lock()
+		if !s.finished {
+			s.timer.Reset(s.timeout - idle)
+		}
+		s.mu.Unlock()
+		return
+	}
+	atomic.StoreInt32(&s.timedOut, 1)
+	s.cancel()
+}
+
+// finish 流结束时停止计时并释放上下文
+func (s *idleStream) finish() {
+	s.mu.Lock()
+	s.finished = true
+	s.timer.Stop()
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// convertErr 因为空闲超时取消了流时，返回codes.DeadlineExceeded错误
+func (s *idleStream) convertErr(err error) error {
+	if err != nil && atomic.LoadInt32(&s.timedOut) == 1 && status.Code(err) == codes.Canceled {
+		return status.Errorf(codes.DeadlineExceeded, "stream idle timeout: %v", s.timeout)
+	}
+	return err
+}
+
+func (s *idleStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+	} else if err != io.EOF {
+		s.finish()
+	}
+	return s.convertErr(err)
+}
+
+func (s *idleStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if isStreamFinished(s.desc, err) {
+		s.finish()
+	} else {
+		atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+	}
+	return s.convertErr(err)
+}
@@ -0,0 +1,19 @@
+package xhttp
+
+import (
+	"github.com/happyxcj/golib/backoff"
+	"time"
+)
+
+// BackoffFn 返回第retries次重试(从1开始)前需要等待的时长
+type BackoffFn = backoff.Fn
+
+// NewExpBackoff 返回指数退避方法，见backoff.NewExp
+func NewExpBackoff(baseDelay time.Duration, multiplier, jitter float64, maxDelay time.Duration) BackoffFn {
+	return backoff.NewExp(baseDelay, multiplier, jitter, maxDelay)
+}
+
+// NewDefaultExpBackoff 返回默认指数退避方法，见backoff.NewDefaultExp
+func NewDefaultExpBackoff(maxDelay time.Duration) BackoffFn {
+	return backoff.NewDefaultExp(maxDelay)
+}
@@ -0,0 +1,74 @@
+package xhttp
+
+import (
+	"github.com/happyxcj/golib/breaker"
+	"net/http"
+)
+
+// BreakerKeyFn 返回请求req使用的熔断器key
+type BreakerKeyFn func(req *http.Request) string
+
+// BreakerKeyByHost 按照请求的host区分熔断器
+func BreakerKeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+// BreakerKeyByPath 按照请求的path区分熔断器，与TimeoutClient.PathTimeouts的key相同
+// 注意：每个path创建一个不会被删除的熔断器，path包含id等参数时应当自定义BreakerKeyFn归一化
+func BreakerKeyByPath(req *http.Request) string {
+	return req.URL.Path
+}
+
+// IsServerFailure 网络错误或者响应状态码>=500时视为失败
+func IsServerFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// 熔断客户端
+// 按照KeyFn区分的熔断器打开时直接返回*breaker.OpenErr，不再请求内部客户端
+type BreakerClient struct {
+	Inner IClient
+	// 熔断器集合
+	Breakers *breaker.Group
+	// 返回请求使用的熔断器key，默认为BreakerKeyByHost
+	KeyFn BreakerKeyFn
+	// 判断请求结果是否为失败，默认为IsServerFailure
+	IsFailureFn func(resp *http.Response, err error) bool
+}
+
+// NewBreakerClient 返回使用熔断器配置cfg的BreakerClient实例，默认按照请求的host区分熔断器
+func NewBreakerClient(inner IClient, cfg breaker.Config) *BreakerClient {
+	return &BreakerClient{
+		Inner:       inner,
+		Breakers:    breaker.NewGroup(cfg),
+		KeyFn:       BreakerKeyByHost,
+		IsFailureFn: IsServerFailure,
+	}
+}
+
+// WithKeyFn 设置区分熔断器的方法keyFn
+func (c *BreakerClient) WithKeyFn(keyFn BreakerKeyFn) *BreakerClient {
+	c.KeyFn = keyFn
+	return c
+}
+
+// WithIsFailureFn 设置判断请求结果是否为失败的方法isFailureFn
+func (c *BreakerClient) WithIsFailureFn(isFailureFn func(resp *http.Response, err error) bool) *BreakerClient {
+	c.IsFailureFn = isFailureFn
+	return c
+}
+
+func (c *BreakerClient) Do(req *http.Request) (*http.Response, error) {
+	b := c.Breakers.Get(c.KeyFn(req))
+	generation, err := b.Allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Inner.Do(req)
+	if c.IsFailureFn(resp, err) {
+		b.MarkFailure(generation)
+	} else {
+		b.MarkSuccess(generation)
+	}
+	return resp, err
+}
@@ -0,0 +1,55 @@
+package xhttp
+
+import (
+	"errors"
+	"github.com/happyxcj/golib/breaker"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerClient(t *testing.T) {
+	Convey("TestBreakerClient", t, func() {
+		var times int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&times, 1)
+			if r.URL.Path == "/fail" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(_testBody))
+		}))
+		var changes []string
+		cfg := breaker.Config{
+			Window:              time.Second,
+			Buckets:             10,
+			ConsecutiveFailures: 2,
+			OpenTimeout:         time.Second,
+			OnStateChange: func(name string, from, to breaker.State) {
+				changes = append(changes, name+":"+to.String())
+			},
+		}
+		inner := NewDefaultBaseClient()
+		cli := NewBreakerClient(inner, cfg).WithKeyFn(BreakerKeyByPath)
+		for i := 0; i < 3; i++ {
+			_, err := cli.Do(mustNewReq(http.MethodGet, server.URL+"/fail"))
+			if i < 2 {
+				So(err, ShouldBeNil)
+				continue
+			}
+			var openErr *breaker.OpenErr
+			So(errors.As(err, &openErr), ShouldBeTrue)
+			So(openErr.Name, ShouldEqual, "/fail")
+		}
+		So(atomic.LoadInt64(&times), ShouldEqual, 2)
+		So(changes, ShouldResemble, []string{"/fail:open"})
+
+		// 其他path不受影响
+		resp, err := _do(cli, server.URL+"/ok")
+		So(err, ShouldBeNil)
+		So(resp, ShouldEqual, _testBody)
+	})
+}
@@ -49,6 +49,8 @@ type MethodClient struct {
 	EncodeFn EncodeReqFn
 	// 响应解码方法
 	DecodeFn DecodeRespFn
+	// 非2xx响应解码方法，为nil时不检查http状态码，所有响应都使用DecodeFn解码
+	DecodeErrFn DecodeErrRespFn
 	// 基本url，可为空，有设置时http请求的最终url=baseUrl+paramUrl(请求参数传的url)
 	baseUrl string
 }
@@ -68,11 +70,23 @@ func NewMethodJsonClient(inner IClient) *MethodClient {
 	return NewMethodClient(inner, EncodeJsonReq, DecodeJsonResp)
 }
 
+// NewMethodECodeJsonClient 返回MethodClient实例，使用json序列化req，反序列化2xx的resp，
+// 非2xx响应为ecode错误信封时DoAndDecode等方法返回*ecode.Err
+func NewMethodECodeJsonClient(inner IClient) *MethodClient {
+	return NewMethodClient(inner, EncodeJsonReq, DecodeJsonResp).WithDecodeErrFn(DecodeECodeErrResp)
+}
+
 func (c *MethodClient) WithBaseUrl(baseUrl string) *MethodClient {
 	c.baseUrl = baseUrl
 	return c
 }
 
+// WithDecodeErrFn 设置非2xx响应解码方法decodeErrFn
+func (c *MethodClient) WithDecodeErrFn(decodeErrFn DecodeErrRespFn) *MethodClient {
+	c.DecodeErrFn = decodeErrFn
+	return c
+}
+
 func (c *MethodClient) Do(req *http.Request) (*http.Response, error) {
 	return c.Inner.Do(req)
 }
@@ -128,6 +142,7 @@ func (c *MethodClient) Delete(url string, reqMsg interface{}) (*http.Response, e
 }
 
 // DoAndDecode 执行指定方法method的http请求，请求body信息为reqMsg，响应成功后反序列化响应到消息respMsg
+// 设置了DecodeErrFn时，非2xx响应不反序列化respMsg，返回DecodeErrFn解码的错误
 // 注意：没有请求体时reqMsg可传nil
 func (c *MethodClient) DoAndDecode(method, url string, reqMsg, respMsg interface{}) error {
 	resp, err := c.DoMethod(method, url, reqMsg)
@@ -135,6 +150,9 @@ func (c *MethodClient) DoAndDecode(method, url string, reqMsg, respMsg interface
 		return err
 	}
 	defer resp.Body.Close()
+	if c.DecodeErrFn != nil && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return c.DecodeErrFn(resp.StatusCode, resp.Body)
+	}
 	return c.DecodeFn(resp.Body, respMsg)
 }
 
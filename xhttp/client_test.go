@@ -1,6 +1,7 @@
 package xhttp
 
 import (
+	"github.com/happyxcj/golib/ecode"
 	. "github.com/smartystreets/goconvey/convey"
 	"net/http"
 	"net/http/httptest"
@@ -144,3 +145,57 @@ func TestMethodClient(t *testing.T) {
 		So(resp.Age, ShouldEqual, 5)
 	})
 }
+
+func TestMethodECodeJsonClient(t *testing.T) {
+	Convey("TestMethodECodeJsonClient", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/test_err":
+				ecode.NewEM(3000, "server error").ServeHTTP(w, r)
+			case "/test_err_detail":
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":4000,"msg":"param error","detail":"name"}`))
+			case "/test_bad_gateway":
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(`bad gateway`))
+			case "/test_ok_envelope":
+				w.Write([]byte(`{"code":0,"msg":"ok"}`))
+			default:
+				w.Write([]byte(`{"age":5}`))
+			}
+		}))
+		cli := NewMethodECodeJsonClient(NewDefaultBaseClient()).WithBaseUrl(server.URL)
+		resp := new(_TestResp)
+		So(cli.GetAndDecode("/test_ok", resp), ShouldBeNil)
+		So(resp.Age, ShouldEqual, 5)
+
+		resp = new(_TestResp)
+		err := cli.GetAndDecode("/test_err", resp)
+		e, ok := err.(*ecode.Err)
+		So(ok, ShouldBeTrue)
+		So(e.Code(), ShouldEqual, 3000)
+		So(e.Msg(), ShouldEqual, "server error")
+		So(resp.Age, ShouldEqual, 0)
+
+		// 非2xx的错误信封可以包含其他字段
+		err = cli.GetAndDecode("/test_err_detail", resp)
+		e, ok = err.(*ecode.Err)
+		So(ok, ShouldBeTrue)
+		So(e.Code(), ShouldEqual, 4000)
+
+		// 非2xx的非错误信封响应返回普通错误
+		err = cli.GetAndDecode("/test_bad_gateway", resp)
+		_, ok = err.(*ecode.Err)
+		So(ok, ShouldBeFalse)
+		So(err.Error(), ShouldEqual, "unexpected http status: 502, body: bad gateway")
+		So(resp.Age, ShouldEqual, 0)
+
+		// 2xx的响应即使形如错误信封也按照成功响应解码
+		envResp := new(struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		})
+		So(cli.GetAndDecode("/test_ok_envelope", envResp), ShouldBeNil)
+		So(envResp.Msg, ShouldEqual, "ok")
+	})
+}
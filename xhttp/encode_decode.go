@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/happyxcj/golib/ecode"
 	"io"
 )
 
@@ -13,6 +15,9 @@ type EncodeReqFn func(reqMsg interface{}) (io.Reader, error)
 // 响应解码方法
 type DecodeRespFn func(reader io.Reader, respMsg interface{}) error
 
+// 非2xx响应解码方法，statusCode为http状态码，返回对应的错误
+type DecodeErrRespFn func(statusCode int, reader io.Reader) error
+
 // EncodeJsonReq 编码指定的json格式请求消息reqMsg
 func EncodeJsonReq(reqMsg interface{}) (io.Reader, error) {
 	reqData, err := json.Marshal(reqMsg)
@@ -32,6 +37,20 @@ func DecodeJsonResp(reader io.Reader, respMsg interface{}) error {
 	return json.Unmarshal(buf.Bytes(), respMsg)
 }
 
+// DecodeECodeErrResp 解码http状态码为statusCode的非2xx响应reader，
+// 响应为ecode错误信封{"code":..,"msg":..}时返回对应的*ecode.Err，否则返回包含状态码和响应内容的错误
+func DecodeECodeErrResp(statusCode int, reader io.Reader) error {
+	buf := bytes.NewBuffer(make([]byte, 0, 4096))
+	_, err := io.Copy(buf, reader)
+	if err != nil {
+		return errors.New("io copy error: " + err.Error())
+	}
+	if e, ok := ecode.DecodeEnvelope(buf.Bytes()); ok {
+		return e
+	}
+	return fmt.Errorf("unexpected http status: %d, body: %s", statusCode, buf.String())
+}
+
 // DecodeJsonResp 解码指定的reader到*string格式响应消息respMsg
 func DecodeStrResp(reader io.Reader, respMsg interface{}) error {
 	buf := bytes.NewBuffer(make([]byte, 0, 4096))
@@ -0,0 +1,159 @@
+package xhttp
+
+import (
+	"bytes"
+	"github.com/happyxcj/golib/internal/timerpool"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// 默认需要重试的响应状态码
+var defaultRetryCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 默认允许重试的请求方法，即幂等方法
+var defaultRetryMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+// 失败重试客户端
+// 请求遇到网络错误或者响应状态码在RetryCodes中时，按照Backoff退避后重试，最多重试MaxRetries次
+// 注意：只有请求方法在RetryMethods中时才会重试，默认只重试幂等方法
+type RetryClient struct {
+	Inner IClient
+	// 最大重试次数，不包括首次请求
+	MaxRetries int
+	// 退避方法，返回每次重试前需要等待的时长
+	Backoff BackoffFn
+	// 需要重试的响应状态码
+	RetryCodes map[int]struct{}
+	// 允许重试的请求方法
+	RetryMethods map[string]struct{}
+}
+
+// NewRetryClient 返回RetryClient实例，最多重试maxRetries次
+// 默认：退避方法为NewDefaultExpBackoff(time.Second)，
+// 重试状态码为429，502，503，504，重试方法为GET，HEAD，OPTIONS，TRACE，PUT，DELETE
+func NewRetryClient(inner IClient, maxRetries int) *RetryClient {
+	c := &RetryClient{
+		Inner:        inner,
+		MaxRetries:   maxRetries,
+		Backoff:      NewDefaultExpBackoff(time.Second),
+		RetryCodes:   make(map[int]struct{}),
+		RetryMethods: make(map[string]struct{}),
+	}
+	return c.AddRetryCodes(defaultRetryCodes...).AddRetryMethods(defaultRetryMethods...)
+}
+
+// WithBackoff 设置退避方法backoff
+func (c *RetryClient) WithBackoff(backoff BackoffFn) *RetryClient {
+	c.Backoff = backoff
+	return c
+}
+
+// AddRetryCodes 添加需要重试的响应状态码codes
+func (c *RetryClient) AddRetryCodes(codes ...int) *RetryClient {
+	for _, code := range codes {
+		c.RetryCodes[code] = struct{}{}
+	}
+	return c
+}
+
+// AddRetryMethods 添加允许重试的请求方法methods，例如非幂等的POST请求在服务端支持去重时也可重试
+func (c *RetryClient) AddRetryMethods(methods ...string) *RetryClient {
+	for _, method := range methods {
+		c.RetryMethods[method] = struct{}{}
+	}
+	return c
+}
+
+func (c *RetryClient) Do(req *http.Request) (*http.Response, error) {
+	if _, ok := c.RetryMethods[req.Method]; !ok || c.MaxRetries <= 0 {
+		return c.Inner.Do(req)
+	}
+	if err := bufferReqBody(req); err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	for retries := 0; ; retries++ {
+		tmpReq := req
+		if retries > 0 {
+			tmpReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				tmpReq.Body = body
+			}
+		}
+		resp, err := c.Inner.Do(tmpReq)
+		if retries >= c.MaxRetries || !c.shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			// 丢弃响应，使连接可以复用
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := c.wait(req, retries+1); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry 返回请求结果是否需要重试
+func (c *RetryClient) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	_, ok := c.RetryCodes[resp.StatusCode]
+	return ok
+}
+
+// wait 等待第retries次重试的退避时长，期间请求上下文结束则返回上下文错误
+func (c *RetryClient) wait(req *http.Request, retries int) error {
+	if c.Backoff == nil {
+		return nil
+	}
+	d := c.Backoff(retries)
+	if d <= 0 {
+		return nil
+	}
+	t := timerpool.Acquire(d)
+	defer timerpool.Release(t)
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// bufferReqBody 缓存请求体req.Body，使请求可以通过req.GetBody重放
+func bufferReqBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
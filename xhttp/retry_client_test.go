@@ -0,0 +1,61 @@
+package xhttp
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryClient(t *testing.T) {
+	Convey("TestRetryClient", t, func() {
+		var times int64
+		var lastBody string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			lastBody = string(data)
+			if atomic.AddInt64(&times, 1)%3 != 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(_testBody))
+		}))
+		inner := NewDefaultBaseClient()
+		cli := NewRetryClient(inner, 2).WithBackoff(NewDefaultExpBackoff(time.Millisecond * 10))
+
+		// 重试两次后成功，且每次重试都携带完整请求体
+		req, _ := NewReq(http.MethodPut, server.URL, strings.NewReader(_testBody))
+		resp, err := cli.Do(req)
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(atomic.LoadInt64(&times), ShouldEqual, 3)
+		So(lastBody, ShouldEqual, _testBody)
+
+		// 非幂等方法默认不重试
+		req, _ = NewReq(http.MethodPost, server.URL, strings.NewReader(_testBody))
+		resp, err = cli.Do(req)
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+		So(atomic.LoadInt64(&times), ShouldEqual, 4)
+
+		// 超过最大重试次数时返回最后一次响应
+		atomic.StoreInt64(&times, 0)
+		cli.MaxRetries = 1
+		resp, err = cli.Do(mustNewReq(http.MethodGet, server.URL))
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+		So(atomic.LoadInt64(&times), ShouldEqual, 2)
+	})
+}
+
+func mustNewReq(method, url string) *http.Request {
+	req, err := NewReq(method, url, nil)
+	if err != nil {
+		panic(err)
+	}
+	return req
+}